@@ -0,0 +1,52 @@
+// Package cmd 提供 xingfinger 的命令行接口
+// 本文件实现 match 子命令：对已保存的 HTTP 响应进行离线指纹识别
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/yyhuni/xingfinger/pkg"
+)
+
+// matchCmd 离线匹配命令
+var matchCmd = &cobra.Command{
+	Use:   "match <file|dir>...",
+	Short: "对已保存的 HTTP 响应进行离线指纹识别",
+	Long: `对已保存的 HTTP 响应进行离线指纹识别，不发送任何网络请求
+
+支持原始 HTTP 响应文件、HAR 文件（.har）、WARC 文件（.warc / .warc.gz）、Burp "Save items" 导出文件（.xml）以及包含这些文件的目录
+识别流程与在线扫描一致（fingers 引擎、ARL 规则、favicon hash），
+favicon 只从导入的响应中查找或从 data: URI 解码，适合在无网络的 CI 环境中回归测试指纹规则`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runMatch,
+}
+
+func init() {
+	rootCmd.AddCommand(matchCmd)
+}
+
+// runMatch 执行离线匹配
+func runMatch(cmd *cobra.Command, args []string) {
+	// 加载所有响应
+	var responses []*pkg.Response
+	for _, path := range args {
+		rs, err := pkg.LoadResponses(path)
+		if err != nil {
+			logger.Errorf("加载响应失败: %v", err)
+			os.Exit(1)
+		}
+		responses = append(responses, rs...)
+	}
+
+	if len(responses) == 0 {
+		logger.Errorf("未找到可识别的 HTTP 响应")
+		os.Exit(1)
+	}
+
+	// 创建扫描器并离线运行
+	scanner := newScanner()
+	ctx, cancel := scanContext()
+	defer cancel()
+	writeResults(ctx, scanner.Match(ctx, responses))
+}
@@ -0,0 +1,126 @@
+// Package cmd 提供 xingfinger 的命令行接口
+// 本文件实现 rules 子命令：指纹规则文件的检查、格式转换等维护工具
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yyhuni/xingfinger/pkg"
+)
+
+// rulesCmd 指纹规则维护命令
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "指纹规则维护工具",
+}
+
+// rulesLintCmd 指纹规则检查命令
+var rulesLintCmd = &cobra.Command{
+	Use:   "lint <file>...",
+	Short: "检查指纹规则文件",
+	Long: `检查指纹规则文件，自动识别格式（EHole、Goby、Wappalyzer、Fingers、FingerPrintHub、ARL、JARM）
+
+校验规则结构、编译所有正则，并标记空的、重复的和过于宽泛（少于 3 个字符）的关键字
+存在 error 级别问题时以非零状态码退出，可用于 CI 检查`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runRulesLint,
+}
+
+// rulesConvertCmd 指纹格式转换命令
+var rulesConvertCmd = &cobra.Command{
+	Use:   "convert <file>",
+	Short: "转换指纹规则格式",
+	Long: `将指纹规则文件转换为其他格式，例如：
+
+  xingfinger rules convert ARL.yaml --from arl --to ehole -o ehole.json
+
+来源格式支持 arl、ehole（默认自动识别），目标格式支持 ehole、fingers、fingerprinthub、arl
+目标格式无法无损表示的规则会被跳过或近似转换，并输出到标准错误`,
+	Args: cobra.ExactArgs(1),
+	Run:  runRulesConvert,
+}
+
+// 转换参数
+var (
+	convertFrom string // 来源格式
+	convertTo   string // 目标格式
+)
+
+func init() {
+	rulesConvertCmd.Flags().StringVar(&convertFrom, "from", "", "来源格式: "+strings.Join(pkg.ConvertReadFormats, "|")+"（默认自动识别）")
+	rulesConvertCmd.Flags().StringVar(&convertTo, "to", "", "目标格式: "+strings.Join(pkg.ConvertWriteFormats, "|"))
+	rulesConvertCmd.MarkFlagRequired("to")
+
+	rulesCmd.AddCommand(rulesLintCmd)
+	rulesCmd.AddCommand(rulesConvertCmd)
+	rootCmd.AddCommand(rulesCmd)
+}
+
+// runRulesLint 执行规则检查
+func runRulesLint(cmd *cobra.Command, args []string) {
+	failed := false
+	for _, path := range args {
+		report, err := pkg.LintRuleFile(path)
+		if err != nil {
+			fmt.Printf("[!] %s: %v\n", path, err)
+			failed = true
+			continue
+		}
+
+		for _, issue := range report.Issues {
+			fmt.Printf("%s:%d:%d: %s: [%s] %s\n", path, issue.Line, issue.Column, issue.Level, issue.Rule, issue.Message)
+		}
+
+		errors := report.ErrorCount()
+		fmt.Printf("[*] %s: 格式 %s, %d 条规则, %d 个错误, %d 个警告\n",
+			path, report.Format, report.Rules, errors, len(report.Issues)-errors)
+		if errors > 0 {
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// runRulesConvert 执行格式转换
+// 转换结果写入 -o 指定的文件，未指定时输出到标准输出；转换报告始终输出到标准错误
+func runRulesConvert(cmd *cobra.Command, args []string) {
+	path := args[0]
+	rules, readIssues, err := pkg.ReadRules(path, convertFrom)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!] %s: %v\n", path, err)
+		os.Exit(1)
+	}
+
+	data, writeIssues, err := pkg.WriteRules(rules, convertTo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!] %v\n", err)
+		os.Exit(1)
+	}
+
+	skipped := 0
+	issues := append(readIssues, writeIssues...)
+	for _, issue := range issues {
+		action := "近似"
+		if issue.Skipped {
+			action = "跳过"
+			skipped++
+		}
+		fmt.Fprintf(os.Stderr, "%s: [%s] %s\n", action, issue.Rule, issue.Message)
+	}
+
+	if output == "" {
+		os.Stdout.Write(data)
+	} else if err := os.WriteFile(output, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "[!] 写入失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "[*] %s: 读取 %d 条规则, 跳过 %d 条, 近似转换 %d 处\n",
+		path, len(rules)+len(readIssues), skipped, len(issues)-skipped)
+}
@@ -0,0 +1,106 @@
+// Package cmd 提供 xingfinger 的命令行接口
+// 本文件实现 serve 子命令：以 HTTP API 服务的形式提供扫描能力
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yyhuni/xingfinger/pkg"
+)
+
+var (
+	listenAddr string // API 服务监听地址
+	maxJobs    int    // 同时执行的任务数
+	queueSize  int    // 排队任务数上限
+	apiToken   string // API 访问令牌
+	jobTTL     int    // 已结束任务的保留时间，秒
+	maxResults int    // 所有任务保存的结果总数上限
+	jobProxy   bool   // 是否允许任务指定代理
+)
+
+// serveCmd API 服务命令
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "启动 HTTP API 服务",
+	Long: `启动 HTTP API 服务，通过 REST API 创建扫描任务、查询进度、流式获取结果和取消任务
+
+指纹引擎只在启动时初始化一次，所有任务共用；任务在有界队列中排队，队列满时拒绝新任务
+扫描参数（--timeout、--proxy / --proxy-file、--probe 等）、请求参数（-X、-H、--cookie 等）、
+-t / --max-time 以及自定义指纹参数作为所有任务的默认配置，单个任务可以在请求中覆盖扫描参数和请求参数
+
+指定 --token 后所有请求需携带 Authorization: Bearer <token>（或 ?token=<token>）；
+已结束的任务在 --job-ttl 后自动删除，保存的结果总数达到 --max-results 时先删除最早结束的任务
+
+接口：
+  POST   /api/jobs              创建任务，请求体 {"targets": [...], "thread": 50, "timeout": 10, "headers": {...}}
+  GET    /api/jobs              列出所有任务
+  GET    /api/jobs/{id}         查询任务状态和进度
+  GET    /api/jobs/{id}/results 流式获取结果（NDJSON，?format=sse 或 Accept: text/event-stream 时为 SSE）
+  DELETE /api/jobs/{id}         取消任务，已结束的任务则删除
+  POST   /api/fingerprint       同步识别单个响应（原始响应报文或 JSON）
+  GET    /api/rules             查询已加载的规则数量
+  POST   /api/rules/reload      重新加载指纹，正在进行的识别使用原指纹完成
+
+收到 SIGHUP 或指定 --watch-rules 后指纹文件变化时同样会重新加载指纹`,
+	Args: cobra.NoArgs,
+	Run:  runServe,
+}
+
+func init() {
+	serveCmd.Flags().SortFlags = false
+	serveCmd.Flags().StringVar(&listenAddr, "listen", ":8080", "监听地址")
+	serveCmd.Flags().IntVar(&maxJobs, "max-jobs", 1, "同时执行的任务数")
+	serveCmd.Flags().IntVar(&queueSize, "queue", 100, "排队任务数上限，队列满时拒绝新任务")
+	serveCmd.Flags().StringVar(&apiToken, "token", "", "API 访问令牌，指定后请求需携带 Authorization: Bearer <token>")
+	serveCmd.Flags().IntVar(&jobTTL, "job-ttl", int(pkg.DefaultJobTTL/time.Second), "已结束任务的保留时间（秒），超过后自动删除")
+	serveCmd.Flags().IntVar(&maxResults, "max-results", pkg.DefaultMaxResults, "所有任务保存的结果总数上限，超过时先删除最早结束的任务")
+	serveCmd.Flags().BoolVar(&jobProxy, "allow-job-proxy", false, "允许任务通过 proxy 参数指定代理（默认只能使用 --proxy / --proxy-file 指定的服务代理）")
+	addScanFlags(serveCmd)
+	rootCmd.AddCommand(serveCmd)
+}
+
+// runServe 启动 API 服务，收到 SIGINT 时取消所有任务并退出
+func runServe(cmd *cobra.Command, args []string) {
+	scanner := newScanner()
+	api := pkg.NewServer(scanner, pkg.ServerOptions{
+		MaxJobs:   maxJobs,
+		QueueSize: queueSize,
+		MaxTime:   time.Duration(maxTime) * time.Second,
+
+		Token:         apiToken,
+		JobTTL:        time.Duration(jobTTL) * time.Second,
+		MaxResults:    maxResults,
+		AllowJobProxy: jobProxy,
+	})
+	server := &http.Server{Addr: listenAddr, Handler: api}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	startReloader(ctx, scanner)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		// 先取消任务，结果流随之结束，再关闭监听
+		api.Close()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if apiToken == "" {
+		logger.Warnf("未指定 --token，任何能访问 %s 的客户端都可以创建扫描任务", listenAddr)
+	}
+	logger.Infof("API 服务已启动: %s", listenAddr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		logger.Errorf("API 服务启动失败: %v", err)
+		os.Exit(1)
+	}
+	<-done
+	logger.Infof("API 服务已停止")
+}
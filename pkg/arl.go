@@ -3,8 +3,12 @@
 package pkg
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -23,36 +27,48 @@ type ARLEngine struct {
 
 // ARLCondition 解析后的单个条件
 type ARLCondition struct {
-	Type    string // body, header, title, icon_hash
+	Type    string // body, header, title, icon_hash, cert
 	Keyword string // 匹配的关键字
 }
 
 // NewARLEngine 创建 ARL 引擎
-func NewARLEngine(filepath string) (*ARLEngine, error) {
-	data, err := os.ReadFile(filepath)
-	if err != nil {
-		return nil, err
-	}
-
+// 多个指纹文件的规则按顺序合并
+func NewARLEngine(paths ...string) (*ARLEngine, error) {
 	var fingerprints []ARLFingerprint
-	if err := yaml.Unmarshal(data, &fingerprints); err != nil {
-		return nil, err
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var fps []ARLFingerprint
+		if err := yaml.Unmarshal(data, &fps); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		fingerprints = append(fingerprints, fps...)
 	}
 
 	return &ARLEngine{fingerprints: fingerprints}, nil
 }
 
+// arlCancelCheck ARL 匹配时每处理多少条规则检查一次 ctx 是否已取消
+const arlCancelCheck = 256
+
 // Match 匹配指纹，返回匹配到的 CMS 名称列表
-func (e *ARLEngine) Match(body, header, title string, faviconHash string) []string {
+// ctx 取消后提前结束，返回已命中的部分结果；cert 为证书文本（主体、颁发者、SAN、序列号），非 HTTPS 响应为空
+func (e *ARLEngine) Match(ctx context.Context, body, header, title string, faviconHash string, cert string) []string {
 	var matched []string
 	seen := make(map[string]bool)
 
-	for _, fp := range e.fingerprints {
+	for i, fp := range e.fingerprints {
+		if i%arlCancelCheck == 0 && ctx.Err() != nil {
+			break
+		}
 		if fp.Rule == "" {
 			continue
 		}
 
-		if e.matchRule(fp.Rule, body, header, title, faviconHash) {
+		if e.matchRule(fp.Rule, body, header, title, faviconHash, cert) {
 			name := extractARLName(fp.Name)
 			if !seen[name] {
 				seen[name] = true
@@ -65,9 +81,9 @@ func (e *ARLEngine) Match(body, header, title string, faviconHash string) []stri
 }
 
 // matchRule 匹配单条规则
-// 规则格式: body="xxx" && header="yyy" && title="zzz"
+// 规则格式: body="xxx" && header="yyy" && title="zzz" && cert="www"
 // 所有条件必须同时满足（AND 关系）
-func (e *ARLEngine) matchRule(rule, body, header, title, faviconHash string) bool {
+func (e *ARLEngine) matchRule(rule, body, header, title, faviconHash, cert string) bool {
 	conditions := parseARLConditions(rule)
 	if len(conditions) == 0 {
 		return false
@@ -75,7 +91,7 @@ func (e *ARLEngine) matchRule(rule, body, header, title, faviconHash string) boo
 
 	// 所有条件都必须匹配
 	for _, cond := range conditions {
-		if !matchCondition(cond, body, header, title, faviconHash) {
+		if !matchCondition(cond, body, header, title, faviconHash, cert) {
 			return false
 		}
 	}
@@ -92,6 +108,7 @@ func parseARLConditions(rule string) []ARLCondition {
 	headerRe := regexp.MustCompile(`header="((?:[^"\\]|\\.)*)"`)
 	titleRe := regexp.MustCompile(`title="((?:[^"\\]|\\.)*)"`)
 	iconHashRe := regexp.MustCompile(`icon_hash="([^"]+)"`)
+	certRe := regexp.MustCompile(`cert="((?:[^"\\]|\\.)*)"`)
 
 	// 提取 body 条件
 	for _, m := range bodyRe.FindAllStringSubmatch(rule, -1) {
@@ -128,7 +145,17 @@ func parseARLConditions(rule string) []ARLCondition {
 		if len(m) > 1 && m[1] != "" {
 			conditions = append(conditions, ARLCondition{
 				Type:    "icon_hash",
-				Keyword: m[1],
+				Keyword: normalizeMMH3(m[1]),
+			})
+		}
+	}
+
+	// 提取 cert 条件
+	for _, m := range certRe.FindAllStringSubmatch(rule, -1) {
+		if len(m) > 1 && m[1] != "" {
+			conditions = append(conditions, ARLCondition{
+				Type:    "cert",
+				Keyword: unescapeARLString(m[1]),
 			})
 		}
 	}
@@ -136,8 +163,83 @@ func parseARLConditions(rule string) []ARLCondition {
 	return conditions
 }
 
+// arlConditionTypes ARL 规则支持的条件类型
+var arlConditionTypes = map[string]bool{
+	"body":      true,
+	"header":    true,
+	"title":     true,
+	"icon_hash": true,
+	"cert":      true,
+}
+
+// parseARLRuleStrict 严格解析 ARL 规则字符串
+// 与匹配时使用的 parseARLConditions 不同，遇到不支持的条件类型、|| 等运算符、
+// 未闭合的引号时返回错误，供规则检查和格式转换使用
+//
+// 参数：
+//   - rule: 规则字符串，如 body="xxx" && header="yyy"
+//
+// 返回：
+//   - []ARLCondition: 条件列表
+//   - error: 语法错误
+func parseARLRuleStrict(rule string) ([]ARLCondition, error) {
+	var conditions []ARLCondition
+	rest := strings.TrimSpace(rule)
+	for rest != "" {
+		eq := strings.Index(rest, "=\"")
+		if eq <= 0 {
+			return nil, fmt.Errorf("无法解析的条件: %s", rest)
+		}
+		key := strings.TrimSpace(rest[:eq])
+		if !arlConditionTypes[key] {
+			return nil, fmt.Errorf("不支持的条件类型: %s", key)
+		}
+
+		// 读取引号内的关键字，处理转义字符
+		i := eq + 2
+		closed := false
+		var keyword strings.Builder
+		for i < len(rest) {
+			c := rest[i]
+			if c == '\\' && i+1 < len(rest) {
+				keyword.WriteByte(c)
+				keyword.WriteByte(rest[i+1])
+				i += 2
+				continue
+			}
+			i++
+			if c == '"' {
+				closed = true
+				break
+			}
+			keyword.WriteByte(c)
+		}
+		if !closed {
+			return nil, fmt.Errorf("引号未闭合: %s", rest)
+		}
+		cond := ARLCondition{Type: key, Keyword: unescapeARLString(keyword.String())}
+		if key == "icon_hash" {
+			cond.Keyword = normalizeMMH3(cond.Keyword)
+		}
+		conditions = append(conditions, cond)
+
+		rest = strings.TrimSpace(rest[i:])
+		if rest == "" {
+			break
+		}
+		if !strings.HasPrefix(rest, "&&") {
+			return nil, fmt.Errorf("条件之间只支持 && 连接: %s", rest)
+		}
+		rest = strings.TrimSpace(rest[2:])
+		if rest == "" {
+			return nil, fmt.Errorf("&& 之后缺少条件")
+		}
+	}
+	return conditions, nil
+}
+
 // matchCondition 匹配单个条件
-func matchCondition(cond ARLCondition, body, header, title, faviconHash string) bool {
+func matchCondition(cond ARLCondition, body, header, title, faviconHash, cert string) bool {
 	switch cond.Type {
 	case "body":
 		return strings.Contains(strings.ToLower(body), strings.ToLower(cond.Keyword))
@@ -147,10 +249,22 @@ func matchCondition(cond ARLCondition, body, header, title, faviconHash string)
 		return strings.Contains(strings.ToLower(title), strings.ToLower(cond.Keyword))
 	case "icon_hash":
 		return faviconHash == cond.Keyword
+	case "cert":
+		return strings.Contains(strings.ToLower(cert), strings.ToLower(cond.Keyword))
 	}
 	return false
 }
 
+// normalizeMMH3 将无符号形式的 mmh3 hash（如 3080850296）转为 favicon 计算使用的有符号形式（-1214117000）
+// ARL 指纹库中两种形式都有；不是 32 位整数时原样返回
+func normalizeMMH3(hash string) string {
+	v, err := strconv.ParseInt(strings.TrimSpace(hash), 10, 64)
+	if err != nil || v < math.MinInt32 || v > math.MaxUint32 {
+		return hash
+	}
+	return strconv.Itoa(int(int32(uint32(v))))
+}
+
 // unescapeARLString 处理转义字符
 func unescapeARLString(s string) string {
 	s = strings.ReplaceAll(s, `\"`, `"`)
@@ -160,7 +274,7 @@ func unescapeARLString(s string) string {
 
 // extractARLName 从 ARL name 中提取干净的名称
 func extractARLName(name string) string {
-	suffixes := []string{"_body", "_header", "_title", "_icon_hash"}
+	suffixes := []string{"_body", "_header", "_title", "_icon_hash", "_cert"}
 	for _, suffix := range suffixes {
 		if strings.HasSuffix(name, suffix) {
 			return strings.TrimSuffix(name, suffix)
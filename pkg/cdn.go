@@ -0,0 +1,269 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件实现 CDN 检测：
+// 1. 目标域名的 CNAME 指向已知 CDN 的域名（如 *.cloudfront.net）
+// 2. 目标 IP 位于 CDN 的 IP 段内（内置 Cloudflare 的 IP 段，可通过文件追加）
+// 3. 响应头中带有 CDN 特有的响应头（如 CF-RAY、X-Amz-Cf-Id），或 Via、X-Cache 等缓存节点的通用响应头
+//
+// 通用响应头也常由源站自己的 Varnish、Squid、nginx 缓存设置，只有通用响应头时结果标记为 weak，不作为排除依据
+//
+// 命中 CDN 时识别到的指纹可能来自 CDN 节点而不是源站
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CDNInfo CDN 检测结果
+type CDNInfo struct {
+	Name     string   `json:"name,omitempty"`  // CDN 厂商，只从通用响应头（Via、X-Cache）判断时为空
+	CNAME    string   `json:"cname,omitempty"` // 目标域名的 CNAME
+	Evidence []string `json:"evidence"`        // 判断依据，如 cname:xxx.cloudfront.net、ip:104.16.0.0/13、header:CF-RAY
+	Weak     bool     `json:"weak,omitempty"`  // 只有缓存节点的通用响应头，可能是源站自己的缓存
+}
+
+// CDNRanges CDN 的 IP 段
+type CDNRanges []cdnRange
+
+// cdnRange 一个 CDN IP 段
+type cdnRange struct {
+	network *net.IPNet
+	name    string // CDN 厂商，可为空
+}
+
+// cdnCNAMEs 已知 CDN 的 CNAME 后缀
+var cdnCNAMEs = []struct {
+	suffix string
+	name   string
+}{
+	{"cloudfront.net", "CloudFront"},
+	{"akamai.net", "Akamai"},
+	{"akamaiedge.net", "Akamai"},
+	{"akamaized.net", "Akamai"},
+	{"akamaihd.net", "Akamai"},
+	{"edgekey.net", "Akamai"},
+	{"edgesuite.net", "Akamai"},
+	{"fastly.net", "Fastly"},
+	{"fastlylb.net", "Fastly"},
+	{"cdn.cloudflare.net", "Cloudflare"},
+	{"azureedge.net", "Azure CDN"},
+	{"azurefd.net", "Azure Front Door"},
+	{"edgecastcdn.net", "Edgecast"},
+	{"systemcdn.net", "Edgecast"},
+	{"incapdns.net", "Imperva"},
+	{"b-cdn.net", "BunnyCDN"},
+	{"cdn77.org", "CDN77"},
+	{"kxcdn.com", "KeyCDN"},
+	{"stackpathdns.com", "StackPath"},
+	{"gcdn.co", "G-Core"},
+	{"vercel-dns.com", "Vercel"},
+	{"kunlunaq.com", "阿里云 CDN"},
+	{"kunlunca.com", "阿里云 CDN"},
+	{"kunlunsl.com", "阿里云 CDN"},
+	{"alikunlun.com", "阿里云 CDN"},
+	{"alikunlun.net", "阿里云 CDN"},
+	{"cdngslb.com", "阿里云 CDN"},
+	{"tbcache.com", "阿里云 CDN"},
+	{"cdn.dnsv1.com", "腾讯云 CDN"},
+	{"dsa.dnsv1.com", "腾讯云 CDN"},
+	{"tdnsv5.com", "腾讯云 CDN"},
+	{"cdntip.com", "腾讯云 CDN"},
+	{"tcdn.qq.com", "腾讯云 CDN"},
+	{"cdnhwc1.com", "华为云 CDN"},
+	{"cdnhwc2.com", "华为云 CDN"},
+	{"bdydns.com", "百度云 CDN"},
+	{"jomodns.com", "百度云 CDN"},
+	{"yunjiasu-cdn.net", "百度云加速"},
+	{"ksyuncdn.com", "金山云 CDN"},
+	{"qiniudns.com", "七牛云 CDN"},
+	{"wscdns.com", "网宿"},
+	{"wsglb0.com", "网宿"},
+	{"lxdns.com", "网宿"},
+	{"chinanetcenter.com", "网宿"},
+	{"ccgslb.com", "蓝汛"},
+	{"ccgslb.net", "蓝汛"},
+}
+
+// cdnHeaders CDN 特有的响应头，value 为空时只要求响应头存在，否则要求响应头的值（小写）包含 value
+// name 为空的是缓存节点的通用响应头，只说明经过了缓存或代理，不能确定厂商
+var cdnHeaders = []struct {
+	header string
+	value  string
+	name   string
+}{
+	{"CF-RAY", "", "Cloudflare"},
+	{"Server", "cloudflare", "Cloudflare"},
+	{"X-Amz-Cf-Id", "", "CloudFront"},
+	{"X-Amz-Cf-Pop", "", "CloudFront"},
+	{"Via", "cloudfront", "CloudFront"},
+	{"X-Fastly-Request-Id", "", "Fastly"},
+	{"X-Served-By", "cache-", "Fastly"},
+	{"Server", "akamaighost", "Akamai"},
+	{"X-Akamai-Transformed", "", "Akamai"},
+	{"Akamai-Cache-Status", "", "Akamai"},
+	{"X-Azure-Ref", "", "Azure CDN"},
+	{"X-Msedge-Ref", "", "Azure CDN"},
+	{"X-Iinfo", "", "Imperva"},
+	{"X-Cdn", "incapsula", "Imperva"},
+	{"X-Sucuri-Id", "", "Sucuri"},
+	{"Server", "bunnycdn", "BunnyCDN"},
+	{"Cdn-Pullzone", "", "BunnyCDN"},
+	{"X-77-Cache", "", "CDN77"},
+	{"Server", "keycdn", "KeyCDN"},
+	{"X-Vercel-Id", "", "Vercel"},
+	{"X-Nf-Request-Id", "", "Netlify"},
+	{"Eagleid", "", "阿里云 CDN"},
+	{"Ali-Swift-Global-Savetime", "", "阿里云 CDN"},
+	{"X-Nws-Log-Uuid", "", "腾讯云 CDN"},
+	{"X-Ws-Request-Id", "", "网宿"},
+	{"Server", "yunjiasu", "百度云加速"},
+	{"Via", "", ""},
+	{"X-Cache", "", ""},
+	{"X-Cache-Hits", "", ""},
+	{"X-Cache-Status", "", ""},
+}
+
+// defaultCDNRanges 内置的 CDN IP 段（Cloudflare 公布的 IP 段）
+var defaultCDNRanges = mustParseCDNRanges("Cloudflare",
+	"173.245.48.0/20", "103.21.244.0/22", "103.22.200.0/22", "103.31.4.0/22",
+	"141.101.64.0/18", "108.162.192.0/18", "190.93.240.0/20", "188.114.96.0/20",
+	"197.234.240.0/22", "198.41.128.0/17", "162.158.0.0/15", "104.16.0.0/13",
+	"104.24.0.0/14", "172.64.0.0/13", "131.0.72.0/22",
+	"2400:cb00::/32", "2606:4700::/32", "2803:f800::/32", "2405:b500::/32",
+	"2405:8100::/32", "2a06:98c0::/29", "2c0f:f248::/32",
+)
+
+// mustParseCDNRanges 解析内置的 IP 段，格式错误时 panic
+func mustParseCDNRanges(name string, cidrs ...string) CDNRanges {
+	ranges := make(CDNRanges, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		ranges = append(ranges, cdnRange{network: network, name: name})
+	}
+	return ranges
+}
+
+// LoadCDNRanges 从文件加载 CDN 的 IP 段，每行为 "CIDR [厂商]"，单个 IP 视为 /32（IPv6 为 /128）
+// 忽略空行和 # 开头的注释
+//
+// 参数：
+//   - filename: IP 段文件
+//
+// 返回：
+//   - CDNRanges: IP 段列表
+//   - error: 文件读取错误或 IP 段无效
+func LoadCDNRanges(filename string) (CDNRanges, error) {
+	lines, err := readListFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges CDNRanges
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		cidr := fields[0]
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: 无效的 IP 段 %q", filename, fields[0])
+		}
+		ranges = append(ranges, cdnRange{network: network, name: strings.Join(fields[1:], " ")})
+	}
+	return ranges, nil
+}
+
+// contains 返回 ip 所在的 IP 段
+func (r CDNRanges) contains(ip net.IP) (cdnRange, bool) {
+	for _, cr := range r {
+		if cr.network.Contains(ip) {
+			return cr, true
+		}
+	}
+	return cdnRange{}, false
+}
+
+// detectCDN 检测目标是否经过 CDN
+// 依次检查 CNAME、IP 段和响应头，使用代理时不查询 CNAME（与 resolveIPs 相同，避免泄露 DNS 请求）
+//
+// 参数：
+//   - ctx: 上下文
+//   - resp: 目标的响应，IPs 已设置
+//
+// 返回：
+//   - *CDNInfo: CDN 信息，没有检测到 CDN 时为 nil
+func (s *Scanner) detectCDN(ctx context.Context, resp *Response) *CDNInfo {
+	info := &CDNInfo{}
+
+	if u, err := url.Parse(resp.URL); err == nil && s.proxies == nil {
+		if cname, err := s.opts.Resolver.LookupCNAME(ctx, u.Hostname()); err == nil && cname != "" {
+			info.CNAME = cname
+			if name, ok := cdnByCNAME(cname); ok {
+				info.add(name, "cname:"+cname)
+			}
+		}
+	}
+
+	for _, raw := range resp.IPs {
+		ip := net.ParseIP(raw)
+		if ip == nil {
+			continue
+		}
+		for _, ranges := range []CDNRanges{defaultCDNRanges, s.opts.CDNRanges} {
+			if cr, ok := ranges.contains(ip); ok {
+				info.add(cr.name, "ip:"+cr.network.String())
+				break
+			}
+		}
+	}
+
+	strong := len(info.Evidence) > 0
+	header := http.Header(resp.HeaderMap)
+	for _, h := range cdnHeaders {
+		values := header.Values(h.header)
+		if len(values) == 0 {
+			continue
+		}
+		if h.value == "" || strings.Contains(strings.ToLower(strings.Join(values, ", ")), h.value) {
+			info.add(h.name, "header:"+h.header)
+			strong = strong || h.name != ""
+		}
+	}
+
+	if len(info.Evidence) == 0 {
+		return nil
+	}
+	info.Weak = !strong
+	s.opts.Logger.Debugf("%s CDN: %s %v", resp.URL, info.Name, info.Evidence)
+	return info
+}
+
+// add 记录一条判断依据，厂商以第一个确定厂商的依据为准
+func (info *CDNInfo) add(name, evidence string) {
+	if info.Name == "" {
+		info.Name = name
+	}
+	info.Evidence = appendUnique(info.Evidence, evidence)
+}
+
+// cdnByCNAME 根据 CNAME 后缀判断 CDN 厂商
+func cdnByCNAME(cname string) (string, bool) {
+	for _, c := range cdnCNAMEs {
+		if cname == c.suffix || strings.HasSuffix(cname, "."+c.suffix) {
+			return c.name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,490 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件实现指纹格式转换
+// 各格式先读取为通用规则模型 Rule，再由目标格式的写入函数输出，
+// 目标格式无法无损表示的规则会被跳过或近似转换，并记录在 ConvertIssue 中
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule 指纹规则的通用模型
+// 一条规则的所有条件为 AND 关系，同名的多条规则为 OR 关系
+type Rule struct {
+	Name       string          // 指纹名称
+	Conditions []RuleCondition // 匹配条件
+}
+
+// RuleCondition 通用模型中的单个匹配条件
+type RuleCondition struct {
+	Location string // 匹配位置：body、header、title、icon_hash、cert
+	Regexp   bool   // Keyword 是否为正则表达式
+	Keyword  string // 关键字、正则或 favicon mmh3 hash
+}
+
+// ConvertIssue 转换时无法无损表示的规则
+type ConvertIssue struct {
+	Rule    string // 规则名称
+	Skipped bool   // true 表示规则被跳过，false 表示已近似转换
+	Message string // 原因
+}
+
+// ConvertReadFormats 支持作为转换来源的格式
+var ConvertReadFormats = []string{FormatARL, FormatEHole}
+
+// ConvertWriteFormats 支持作为转换目标的格式
+var ConvertWriteFormats = []string{FormatEHole, FormatFingers, FormatFingerPrint, FormatARL}
+
+// ReadRules 读取指纹文件并转换为通用规则模型
+//
+// 参数：
+//   - path: 指纹文件路径
+//   - format: 文件格式，为空时自动识别
+//
+// 返回：
+//   - []Rule: 规则列表
+//   - []ConvertIssue: 无法读入通用模型的规则
+//   - error: 读取错误或不支持的格式
+func ReadRules(path, format string) ([]Rule, []ConvertIssue, error) {
+	data, err := readRuleFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if format == "" {
+		root, err := parseRuleNode(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		format = detectNodeFormat(root)
+	}
+
+	switch format {
+	case FormatARL:
+		return readARLRules(data)
+	case FormatEHole:
+		return readEHoleRules(data)
+	}
+	return nil, nil, fmt.Errorf("不支持从 %q 格式转换，支持: %s", format, strings.Join(ConvertReadFormats, ", "))
+}
+
+// WriteRules 将通用规则模型输出为目标格式
+//
+// 参数：
+//   - rules: 规则列表
+//   - format: 目标格式
+//
+// 返回：
+//   - []byte: 目标格式的文件内容
+//   - []ConvertIssue: 被跳过或近似转换的规则
+//   - error: 不支持的格式、没有匹配条件的规则或序列化错误
+func WriteRules(rules []Rule, format string) ([]byte, []ConvertIssue, error) {
+	for i, rule := range rules {
+		if len(rule.Conditions) == 0 {
+			return nil, nil, fmt.Errorf("第 %d 条规则 %q 没有匹配条件", i+1, rule.Name)
+		}
+	}
+
+	var write func([]Rule) ([]byte, []ConvertIssue, error)
+	switch format {
+	case FormatEHole:
+		write = writeEHoleRules
+	case FormatFingers:
+		write = writeFingersRules
+	case FormatFingerPrint:
+		write = writeFingerPrintRules
+	case FormatARL:
+		return writeARLRules(rules)
+	default:
+		return nil, nil, fmt.Errorf("不支持转换为 %q 格式，支持: %s", format, strings.Join(ConvertWriteFormats, ", "))
+	}
+
+	// 只有 ARL 格式支持证书条件
+	var kept []Rule
+	var issues []ConvertIssue
+	for _, rule := range rules {
+		if hasLocation(rule, "cert") {
+			issues = append(issues, ConvertIssue{Rule: rule.Name, Skipped: true, Message: format + " 格式不支持 cert 证书条件"})
+			continue
+		}
+		kept = append(kept, rule)
+	}
+	data, writeIssues, err := write(kept)
+	return data, append(issues, writeIssues...), err
+}
+
+// hasLocation 判断规则是否包含指定位置的条件
+func hasLocation(rule Rule, location string) bool {
+	for _, cond := range rule.Conditions {
+		if cond.Location == location {
+			return true
+		}
+	}
+	return false
+}
+
+// readARLRules 读取 ARL YAML 规则
+// 与匹配时一致：规则名称去掉 _body、_header 等后缀，关键字为空的条件（如 body=""）被忽略；
+// 去掉后缀后名称为空的规则跳过
+func readARLRules(data []byte) ([]Rule, []ConvertIssue, error) {
+	var fingerprints []ARLFingerprint
+	if err := yaml.Unmarshal(data, &fingerprints); err != nil {
+		return nil, nil, err
+	}
+
+	var rules []Rule
+	var issues []ConvertIssue
+	for _, fp := range fingerprints {
+		name := extractARLName(fp.Name)
+		if strings.TrimSpace(name) == "" {
+			issues = append(issues, ConvertIssue{Rule: fp.Name, Skipped: true, Message: "规则名称为空"})
+			continue
+		}
+		conditions, err := parseARLRuleStrict(fp.Rule)
+		if err != nil {
+			issues = append(issues, ConvertIssue{Rule: name, Skipped: true, Message: err.Error()})
+			continue
+		}
+
+		rule := Rule{Name: name}
+		for _, cond := range conditions {
+			if cond.Keyword == "" {
+				continue
+			}
+			rule.Conditions = append(rule.Conditions, RuleCondition{Location: cond.Type, Keyword: cond.Keyword})
+		}
+		if len(rule.Conditions) == 0 {
+			issues = append(issues, ConvertIssue{Rule: name, Skipped: true, Message: "rule 为空"})
+			continue
+		}
+		if len(rule.Conditions) < len(conditions) {
+			issues = append(issues, ConvertIssue{Rule: name, Message: "关键字为空的条件已忽略（与匹配时一致）"})
+		}
+		rules = append(rules, rule)
+	}
+	return rules, issues, nil
+}
+
+// eholeFingerprint EHole 格式的单条规则
+type eholeFingerprint struct {
+	Cms      string   `json:"cms"`
+	Method   string   `json:"method"`
+	Location string   `json:"location"`
+	Keyword  []string `json:"keyword"`
+}
+
+// eholeFile EHole 格式的文件结构
+type eholeFile struct {
+	Fingerprint []eholeFingerprint `json:"fingerprint"`
+}
+
+// readEHoleRules 读取 EHole JSON 规则
+// faviconhash 规则的多个 hash 为 OR 关系，拆分为多条规则
+func readEHoleRules(data []byte) ([]Rule, []ConvertIssue, error) {
+	var file eholeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, nil, err
+	}
+
+	var rules []Rule
+	var issues []ConvertIssue
+	for _, fp := range file.Fingerprint {
+		if len(fp.Keyword) == 0 {
+			issues = append(issues, ConvertIssue{Rule: fp.Cms, Skipped: true, Message: "keyword 为空"})
+			continue
+		}
+		switch fp.Method {
+		case "keyword", "regular":
+			rule := Rule{Name: fp.Cms}
+			for _, kw := range fp.Keyword {
+				rule.Conditions = append(rule.Conditions, RuleCondition{
+					Location: fp.Location,
+					Regexp:   fp.Method == "regular",
+					Keyword:  kw,
+				})
+			}
+			rules = append(rules, rule)
+		case "faviconhash":
+			for _, hash := range fp.Keyword {
+				rules = append(rules, Rule{Name: fp.Cms, Conditions: []RuleCondition{{Location: "icon_hash", Keyword: hash}}})
+			}
+		default:
+			issues = append(issues, ConvertIssue{Rule: fp.Cms, Skipped: true, Message: fmt.Sprintf("不支持的 method: %q", fp.Method)})
+		}
+	}
+	return rules, issues, nil
+}
+
+// marshalJSON 以缩进格式输出 JSON，不转义 HTML 字符（关键字中经常包含 < >）
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeEHoleRules 输出 EHole 格式
+// EHole 的一条规则只有一个 method 和 location，关键字之间为 AND 关系：
+//   - 条件位置和类型一致的规则可以无损转换
+//   - 单个 icon_hash 条件转换为 faviconhash 规则
+//   - 混合多个位置的规则无法表示，跳过
+//   - title 条件会被 EHole 引擎在整个 body 中匹配，近似转换
+func writeEHoleRules(rules []Rule) ([]byte, []ConvertIssue, error) {
+	file := eholeFile{Fingerprint: []eholeFingerprint{}}
+	var issues []ConvertIssue
+
+	for _, rule := range rules {
+		first := rule.Conditions[0]
+		if first.Location == "icon_hash" {
+			if len(rule.Conditions) > 1 {
+				issues = append(issues, ConvertIssue{Rule: rule.Name, Skipped: true, Message: "EHole 的 faviconhash 规则不能与其他条件组合"})
+				continue
+			}
+			file.Fingerprint = append(file.Fingerprint, eholeFingerprint{
+				Cms: rule.Name, Method: "faviconhash", Location: "body", Keyword: []string{first.Keyword},
+			})
+			continue
+		}
+
+		fp := eholeFingerprint{Cms: rule.Name, Method: "keyword", Location: first.Location}
+		if first.Regexp {
+			fp.Method = "regular"
+		}
+		mixed := false
+		for _, cond := range rule.Conditions {
+			if cond.Location != first.Location || cond.Regexp != first.Regexp {
+				mixed = true
+				break
+			}
+			fp.Keyword = append(fp.Keyword, cond.Keyword)
+		}
+		if mixed {
+			issues = append(issues, ConvertIssue{Rule: rule.Name, Skipped: true, Message: "EHole 规则只能有一个匹配位置和匹配方式，无法表示多位置的 AND 条件"})
+			continue
+		}
+		if fp.Location == "title" {
+			issues = append(issues, ConvertIssue{Rule: rule.Name, Message: "EHole 引擎在整个 body 中匹配 title 关键字，匹配范围变大"})
+		}
+		file.Fingerprint = append(file.Fingerprint, fp)
+	}
+
+	data, err := marshalJSON(file)
+	return data, issues, err
+}
+
+// fingersFinger chainreactors fingers 格式的单个指纹
+type fingersFinger struct {
+	Name string        `json:"name"`
+	Rule []fingersRule `json:"rule"`
+	Tag  []string      `json:"tag,omitempty"`
+}
+
+// fingersRule fingers 格式的单条规则
+type fingersRule struct {
+	Regexps *fingersRegexps `json:"regexps,omitempty"`
+	Favicon *fingersFavicon `json:"favicon,omitempty"`
+}
+
+// fingersRegexps fingers 规则的匹配内容
+type fingersRegexps struct {
+	Body   []string `json:"body,omitempty"`
+	Header []string `json:"header,omitempty"`
+	Regexp []string `json:"regexp,omitempty"`
+}
+
+// fingersFavicon fingers 规则的 favicon hash
+type fingersFavicon struct {
+	Mmh3 []string `json:"mmh3,omitempty"`
+}
+
+// writeFingersRules 输出 chainreactors fingers 格式
+// fingers 的同一条规则内任一关键字命中即匹配（OR 关系），因此：
+//   - 单条件规则可以无损转换，同名规则合并到同一个指纹
+//   - 多条件的 AND 规则无法表示，跳过
+//   - title 条件转换为匹配 <title> 标签的正则；正则会在完整响应中匹配，近似转换
+func writeFingersRules(rules []Rule) ([]byte, []ConvertIssue, error) {
+	var fingers []*fingersFinger
+	index := make(map[string]*fingersFinger)
+	var issues []ConvertIssue
+
+	for _, rule := range rules {
+		if len(rule.Conditions) > 1 {
+			issues = append(issues, ConvertIssue{Rule: rule.Name, Skipped: true, Message: "fingers 规则内的条件为 OR 关系，无法表示多条件的 AND 规则"})
+			continue
+		}
+
+		cond := rule.Conditions[0]
+		var r fingersRule
+		switch {
+		case cond.Location == "icon_hash":
+			r.Favicon = &fingersFavicon{Mmh3: []string{cond.Keyword}}
+		case cond.Location == "title":
+			r.Regexps = &fingersRegexps{Regexp: []string{titleRegexp(cond)}}
+			issues = append(issues, ConvertIssue{Rule: rule.Name, Message: "title 条件转换为 <title> 标签正则"})
+		case cond.Regexp:
+			r.Regexps = &fingersRegexps{Regexp: []string{cond.Keyword}}
+			issues = append(issues, ConvertIssue{Rule: rule.Name, Message: "fingers 的正则在完整响应（包括响应头）中匹配"})
+		case cond.Location == "header":
+			r.Regexps = &fingersRegexps{Header: []string{cond.Keyword}}
+		default:
+			r.Regexps = &fingersRegexps{Body: []string{cond.Keyword}}
+		}
+
+		finger, ok := index[rule.Name]
+		if !ok {
+			finger = &fingersFinger{Name: rule.Name}
+			index[rule.Name] = finger
+			fingers = append(fingers, finger)
+		}
+		finger.Rule = append(finger.Rule, r)
+	}
+
+	if fingers == nil {
+		fingers = []*fingersFinger{}
+	}
+	data, err := marshalJSON(fingers)
+	return data, issues, err
+}
+
+// titleRegexp 将 title 条件转换为匹配 <title> 标签内容的正则
+func titleRegexp(cond RuleCondition) string {
+	keyword := regexp.QuoteMeta(cond.Keyword)
+	if cond.Regexp {
+		keyword = cond.Keyword
+	}
+	return `(?i)<title[^>]*>[^<]*` + keyword
+}
+
+// fingerPrintTemplate FingerPrintHub v4 模板（nuclei 模板的 JSON 形式）
+type fingerPrintTemplate struct {
+	ID   string `json:"id"`
+	Info struct {
+		Name     string `json:"name"`
+		Author   string `json:"author"`
+		Tags     string `json:"tags"`
+		Severity string `json:"severity"`
+		Metadata struct {
+			Product  string `json:"product"`
+			Vendor   string `json:"vendor"`
+			Verified bool   `json:"verified"`
+		} `json:"metadata"`
+	} `json:"info"`
+	HTTP []fingerPrintRequest `json:"http"`
+}
+
+// fingerPrintRequest FingerPrintHub 模板中的请求
+type fingerPrintRequest struct {
+	Method            string               `json:"method"`
+	Path              []string             `json:"path"`
+	MatchersCondition string               `json:"matchers-condition,omitempty"`
+	Matchers          []fingerPrintMatcher `json:"matchers"`
+}
+
+// fingerPrintMatcher FingerPrintHub 模板中的匹配器
+type fingerPrintMatcher struct {
+	Type            string   `json:"type"`
+	Part            string   `json:"part,omitempty"`
+	Words           []string `json:"words,omitempty"`
+	Regex           []string `json:"regex,omitempty"`
+	Hash            []string `json:"hash,omitempty"`
+	CaseInsensitive bool     `json:"case-insensitive,omitempty"`
+}
+
+// templateIDRegexp 模板 id 中不允许出现的字符
+var templateIDRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+
+// writeFingerPrintRules 输出 FingerPrintHub v4 格式
+// 每条规则生成一个模板，多个条件通过 matchers-condition: and 组合，
+// 同名规则生成多个 id 不同、info.name 相同的模板；title 条件转换为 <title> 标签正则
+func writeFingerPrintRules(rules []Rule) ([]byte, []ConvertIssue, error) {
+	templates := []fingerPrintTemplate{}
+	ids := make(map[string]int)
+	var issues []ConvertIssue
+
+	for _, rule := range rules {
+		// 生成唯一的模板 id
+		base := strings.Trim(templateIDRegexp.ReplaceAllString(strings.ToLower(rule.Name), "-"), "-")
+		if base == "" {
+			base = "rule"
+		}
+		ids[base]++
+		id := base
+		if ids[base] > 1 {
+			id = fmt.Sprintf("%s-%d", base, ids[base])
+		}
+
+		var t fingerPrintTemplate
+		t.ID = id
+		t.Info.Name = rule.Name
+		t.Info.Author = "xingfinger"
+		t.Info.Tags = "detect,tech," + base
+		t.Info.Severity = "info"
+		t.Info.Metadata.Product = rule.Name
+		t.Info.Metadata.Vendor = "00_unknown"
+
+		req := fingerPrintRequest{Method: "GET", Path: []string{"{{BaseURL}}/"}}
+		if len(rule.Conditions) > 1 {
+			req.MatchersCondition = "and"
+		}
+		for _, cond := range rule.Conditions {
+			var m fingerPrintMatcher
+			switch {
+			case cond.Location == "icon_hash":
+				m = fingerPrintMatcher{Type: "favicon", Hash: []string{cond.Keyword}}
+			case cond.Location == "title":
+				m = fingerPrintMatcher{Type: "regex", Regex: []string{titleRegexp(cond)}}
+				issues = append(issues, ConvertIssue{Rule: rule.Name, Message: "title 条件转换为 <title> 标签正则"})
+			case cond.Regexp:
+				m = fingerPrintMatcher{Type: "regex", Regex: []string{"(?i)" + cond.Keyword}}
+			default:
+				m = fingerPrintMatcher{Type: "word", Words: []string{cond.Keyword}, CaseInsensitive: true}
+			}
+			if cond.Location == "header" {
+				m.Part = "header"
+			}
+			req.Matchers = append(req.Matchers, m)
+		}
+		t.HTTP = []fingerPrintRequest{req}
+		templates = append(templates, t)
+	}
+
+	data, err := marshalJSON(templates)
+	return data, issues, err
+}
+
+// writeARLRules 输出 ARL YAML 格式
+// ARL 只支持关键字匹配，正则条件无法表示，跳过
+func writeARLRules(rules []Rule) ([]byte, []ConvertIssue, error) {
+	fingerprints := []ARLFingerprint{}
+	var issues []ConvertIssue
+
+	for _, rule := range rules {
+		var parts []string
+		for _, cond := range rule.Conditions {
+			if cond.Regexp {
+				parts = nil
+				break
+			}
+			keyword := strings.ReplaceAll(cond.Keyword, `\`, `\\`)
+			keyword = strings.ReplaceAll(keyword, `"`, `\"`)
+			parts = append(parts, fmt.Sprintf(`%s="%s"`, cond.Location, keyword))
+		}
+		if parts == nil {
+			issues = append(issues, ConvertIssue{Rule: rule.Name, Skipped: true, Message: "ARL 规则不支持正则匹配"})
+			continue
+		}
+		fingerprints = append(fingerprints, ARLFingerprint{Name: rule.Name, Rule: strings.Join(parts, " && ")})
+	}
+
+	data, err := yaml.Marshal(fingerprints)
+	return data, issues, err
+}
@@ -0,0 +1,244 @@
+package pkg
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTemp 将内容写入临时目录中的文件并返回路径
+func writeTemp(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// issueRules 返回被跳过（skipped 为 true）或近似转换（skipped 为 false）的规则名称
+func issueRules(issues []ConvertIssue, skipped bool) []string {
+	var names []string
+	for _, issue := range issues {
+		if issue.Skipped == skipped {
+			names = append(names, issue.Rule)
+		}
+	}
+	return names
+}
+
+func TestReadRulesARL(t *testing.T) {
+	path := writeTemp(t, "arl.yaml", `
+- name: nginx_body
+  rule: 'body="nginx" && header="Server: nginx"'
+- name: Gitlab
+  rule: title="GitLab" || icon_hash="1278323681"
+- name: Fortinet_cert
+  rule: cert="Fortinet"
+- name: broken
+  rule: body="unterminated
+- name: _header
+  rule: header="nameless"
+- name: Jupyter
+  rule: 'body="" && title="Jupyter Notebook"'
+- name: Blank
+  rule: body=""
+`)
+	rules, issues, err := ReadRules(path, "")
+	if err != nil {
+		t.Fatalf("ReadRules: %v", err)
+	}
+	names := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		names = append(names, rule.Name)
+	}
+	if len(rules) == 0 || rules[0].Name != "nginx" {
+		t.Fatalf("rules = %v, want nginx first (_body suffix removed)", names)
+	}
+	want := []RuleCondition{{Location: "body", Keyword: "nginx"}, {Location: "header", Keyword: "Server: nginx"}}
+	if !reflect.DeepEqual(rules[0].Conditions, want) {
+		t.Errorf("nginx conditions = %+v, want %+v", rules[0].Conditions, want)
+	}
+	if !hasRule(rules, "Fortinet", "cert") {
+		t.Errorf("rules = %v, want Fortinet cert rule", names)
+	}
+	if got := issueRules(issues, true); !reflect.DeepEqual(got, []string{"Gitlab", "broken", "_header", "Blank"}) {
+		t.Errorf("skipped = %v, want [Gitlab broken _header Blank] (OR, unterminated, nameless and empty rules)", got)
+	}
+	// 与匹配时一致，关键字为空的条件被忽略
+	if got := issueRules(issues, false); !reflect.DeepEqual(got, []string{"Jupyter"}) {
+		t.Errorf("approximate = %v, want [Jupyter]", got)
+	}
+	if last := rules[len(rules)-1]; last.Name != "Jupyter" ||
+		!reflect.DeepEqual(last.Conditions, []RuleCondition{{Location: "title", Keyword: "Jupyter Notebook"}}) {
+		t.Errorf("Jupyter rule = %+v", last)
+	}
+}
+
+// hasRule 判断是否有指定名称且包含指定位置条件的规则
+func hasRule(rules []Rule, name, location string) bool {
+	for _, rule := range rules {
+		if rule.Name == name && hasLocation(rule, location) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReadRulesEHole(t *testing.T) {
+	path := writeTemp(t, "finger.json", `{"fingerprint": [
+		{"cms": "Tomcat", "method": "keyword", "location": "body", "keyword": ["Apache Tomcat", "manager"]},
+		{"cms": "Jenkins", "method": "regular", "location": "header", "keyword": ["X-Jenkins: [0-9.]+"]},
+		{"cms": "Spring", "method": "faviconhash", "location": "body", "keyword": ["116323821", "-1234"]},
+		{"cms": "Empty", "method": "keyword", "location": "body", "keyword": []},
+		{"cms": "Odd", "method": "xpath", "location": "body", "keyword": ["//a"]}
+	]}`)
+	rules, issues, err := ReadRules(path, FormatEHole)
+	if err != nil {
+		t.Fatalf("ReadRules: %v", err)
+	}
+	want := []Rule{
+		{Name: "Tomcat", Conditions: []RuleCondition{{Location: "body", Keyword: "Apache Tomcat"}, {Location: "body", Keyword: "manager"}}},
+		{Name: "Jenkins", Conditions: []RuleCondition{{Location: "header", Regexp: true, Keyword: "X-Jenkins: [0-9.]+"}}},
+		{Name: "Spring", Conditions: []RuleCondition{{Location: "icon_hash", Keyword: "116323821"}}},
+		{Name: "Spring", Conditions: []RuleCondition{{Location: "icon_hash", Keyword: "-1234"}}},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("rules = %+v, want %+v", rules, want)
+	}
+	if got := issueRules(issues, true); !reflect.DeepEqual(got, []string{"Empty", "Odd"}) {
+		t.Errorf("skipped = %v, want [Empty Odd]", got)
+	}
+
+	if _, _, err := ReadRules(path, FormatGoby); err == nil {
+		t.Error("ReadRules(goby): want unsupported format error")
+	}
+}
+
+// convertRules 转换测试使用的通用规则
+var convertRules = []Rule{
+	{Name: "nginx", Conditions: []RuleCondition{{Location: "body", Keyword: "nginx"}}},
+	{Name: "nginx", Conditions: []RuleCondition{{Location: "header", Keyword: "Server: nginx"}}},
+	{Name: "Login", Conditions: []RuleCondition{{Location: "title", Keyword: "Login (admin)"}}},
+	{Name: "Spring", Conditions: []RuleCondition{{Location: "icon_hash", Keyword: "116323821"}}},
+	{Name: "Mixed", Conditions: []RuleCondition{{Location: "body", Keyword: "a"}, {Location: "header", Keyword: "b"}}},
+	{Name: "Regex", Conditions: []RuleCondition{{Location: "body", Regexp: true, Keyword: `ver(\d+)`}}},
+	{Name: "Quote", Conditions: []RuleCondition{{Location: "body", Keyword: `say "hi" \o/`}}},
+	{Name: "Cert", Conditions: []RuleCondition{{Location: "cert", Keyword: "Fortinet"}}},
+}
+
+func TestWriteRules(t *testing.T) {
+	tests := []struct {
+		format      string
+		skipped     []string
+		approximate []string
+	}{
+		{FormatEHole, []string{"Cert", "Mixed"}, []string{"Login"}},
+		{FormatFingers, []string{"Cert", "Mixed"}, []string{"Login", "Regex"}},
+		{FormatFingerPrint, []string{"Cert"}, []string{"Login"}},
+		{FormatARL, []string{"Regex"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			data, issues, err := WriteRules(convertRules, tt.format)
+			if err != nil {
+				t.Fatalf("WriteRules: %v", err)
+			}
+			if got := issueRules(issues, true); !reflect.DeepEqual(got, tt.skipped) {
+				t.Errorf("skipped = %v, want %v", got, tt.skipped)
+			}
+			if got := issueRules(issues, false); !reflect.DeepEqual(got, tt.approximate) {
+				t.Errorf("approximate = %v, want %v", got, tt.approximate)
+			}
+			if len(data) == 0 {
+				t.Error("empty output")
+			}
+		})
+	}
+
+	if _, _, err := WriteRules(convertRules, FormatGoby); err == nil {
+		t.Error("WriteRules(goby): want unsupported format error")
+	}
+
+	// 没有匹配条件的规则返回错误而不是 panic
+	empty := append([]Rule{{Name: "Empty"}}, convertRules...)
+	for _, format := range ConvertWriteFormats {
+		if _, _, err := WriteRules(empty, format); err == nil {
+			t.Errorf("WriteRules(%s, rule without conditions): want error", format)
+		}
+	}
+}
+
+func TestWriteFingersMergesNames(t *testing.T) {
+	data, _, err := WriteRules(convertRules, FormatFingers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fingers []fingersFinger
+	if err := json.Unmarshal(data, &fingers); err != nil {
+		t.Fatal(err)
+	}
+	if fingers[0].Name != "nginx" || len(fingers[0].Rule) != 2 {
+		t.Fatalf("first finger = %+v, want nginx with 2 rules", fingers[0])
+	}
+	if !reflect.DeepEqual(fingers[0].Rule[1].Regexps.Header, []string{"Server: nginx"}) {
+		t.Errorf("nginx header rule = %+v", fingers[0].Rule[1].Regexps)
+	}
+	// 正则不转义 HTML 字符，title 转换为 <title> 标签正则
+	if !strings.Contains(string(data), `<title[^>]*>[^<]*Login \\(admin\\)`) {
+		t.Errorf("title regexp not found in %s", data)
+	}
+}
+
+func TestWriteFingerPrintTemplates(t *testing.T) {
+	data, _, err := WriteRules(convertRules, FormatFingerPrint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var templates []fingerPrintTemplate
+	if err := json.Unmarshal(data, &templates); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, tpl := range templates {
+		ids = append(ids, tpl.ID)
+	}
+	want := []string{"nginx", "nginx-2", "login", "spring", "mixed", "regex", "quote"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+	mixed := templates[4].HTTP[0]
+	if mixed.MatchersCondition != "and" || len(mixed.Matchers) != 2 || mixed.Matchers[1].Part != "header" {
+		t.Errorf("mixed request = %+v", mixed)
+	}
+	if m := templates[3].HTTP[0].Matchers[0]; m.Type != "favicon" || !reflect.DeepEqual(m.Hash, []string{"116323821"}) {
+		t.Errorf("favicon matcher = %+v", m)
+	}
+}
+
+// TestConvertRoundTrip ARL 输出的规则再读回时与原规则一致（正则规则除外）
+func TestConvertRoundTrip(t *testing.T) {
+	data, _, err := WriteRules(convertRules, FormatARL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, issues, err := ReadRules(writeTemp(t, "out.yaml", string(data)), FormatARL)
+	if err != nil {
+		t.Fatalf("ReadRules: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("issues = %+v", issues)
+	}
+	var want []Rule
+	for _, rule := range convertRules {
+		if rule.Name != "Regex" {
+			want = append(want, rule)
+		}
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("round trip = %+v, want %+v", rules, want)
+	}
+}
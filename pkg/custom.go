@@ -1,161 +1,188 @@
 // Package pkg 提供 xingfinger 的核心功能
-// 本文件实现自定义指纹文件加载功能
+// 本文件实现指纹加载功能：合并内置指纹与自定义指纹并构建指纹引擎
 package pkg
 
 import (
-	"bytes"
 	"compress/gzip"
+	"encoding/json"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
 	"strings"
 
+	"github.com/chainreactors/fingers"
+	"github.com/chainreactors/fingers/common"
+	"github.com/chainreactors/fingers/ehole"
+	"github.com/chainreactors/fingers/favicon"
+	"github.com/chainreactors/fingers/fingerprinthub"
+	fingersengine "github.com/chainreactors/fingers/fingers"
+	"github.com/chainreactors/fingers/goby"
 	"github.com/chainreactors/fingers/resources"
+	"github.com/chainreactors/fingers/wappalyzer"
 )
 
 // CustomFingerConfig 自定义指纹配置
+// 每个字段都可以是文件、目录或通配符，同一格式的多个文件合并加载
 type CustomFingerConfig struct {
-	EHole       string // EHole 格式指纹文件路径
-	Goby        string // Goby 格式指纹文件路径
-	Wappalyzer  string // Wappalyzer 格式指纹文件路径
-	Fingers     string // Fingers 原生格式指纹文件路径
-	FingerPrint string // FingerPrintHub 格式指纹文件路径
-	ARL         string // ARL YAML 格式指纹文件路径
-	NoDefault   bool   // 禁用默认指纹
+	EHole       []string // EHole 格式指纹
+	Goby        []string // Goby 格式指纹
+	Wappalyzer  []string // Wappalyzer 格式指纹
+	Fingers     []string // Fingers 原生格式指纹
+	FingerPrint []string // FingerPrintHub 格式指纹
+	ARL         []string // ARL YAML 格式指纹
+	JARM        []string // JARM hash 指纹，只在开启 JARM 探测时使用
+	RulesDirs   []string // 自动识别格式的指纹文件、目录或通配符
+	BundledDir  string   // 随程序发布的指纹目录（fingerprints/），其中的文件自动识别格式并替换同格式的内置指纹
+	NoDefault   bool     // 禁用默认指纹
+	Override    bool     // 自定义指纹按名称覆盖内置指纹中的同名规则，默认叠加
 }
 
-// LoadCustomFingerprints 加载自定义指纹文件
-// 根据配置加载用户指定的指纹文件
-// 自定义指纹默认与内置指纹叠加使用，除非指定 NoDefault
+// engineFormats fingers 引擎支持的指纹格式，按初始化顺序排列
+var engineFormats = []struct {
+	format string
+	name   string
+}{
+	{FormatFingers, "Fingers"},
+	{FormatFingerPrint, "FingerPrintHub"},
+	{FormatWappalyzer, "Wappalyzer"},
+	{FormatEHole, "EHole"},
+	{FormatGoby, "Goby"},
+}
+
+// LoadFingerprints 加载内置指纹和自定义指纹，构建指纹引擎
+// 每种格式的默认指纹（指纹目录中的文件或内置指纹）与自定义指纹合并后构建为同一个引擎，
+// 不修改 fingers resources 包中的全局数据，同一进程中的多个扫描器可以使用不同的指纹集合
 //
 // 参数：
-//   - config: 自定义指纹配置
-//   - silent: 是否静默模式（不输出加载信息）
+//   - config: 自定义指纹配置，为 nil 时只使用内置指纹
+//   - log: 加载信息的日志，为 nil 时不输出
 //
 // 返回：
+//   - *fingers.Engine: fingers 指纹引擎
+//   - *ARLEngine: ARL 指纹引擎，没有 ARL 指纹时为 nil
 //   - error: 加载错误
-func LoadCustomFingerprints(config *CustomFingerConfig, silent bool) error {
-	// 如果指定了 NoDefault，清空所有内置指纹
+func LoadFingerprints(config *CustomFingerConfig, log *Logger) (*fingers.Engine, *ARLEngine, error) {
+	engine, arlEngine, _, err := loadFingerprints(config, log)
+	return engine, arlEngine, err
+}
+
+// loadFingerprints 加载指纹并构建指纹引擎，同时返回构建 fingers 引擎使用的各格式指纹数据
+// 指纹数据用于提取引擎没有公开的规则信息，如 FingerPrintHub 模板中的请求路径
+func loadFingerprints(config *CustomFingerConfig, log *Logger) (*fingers.Engine, *ARLEngine, map[string][]byte, error) {
+	if config == nil {
+		config = &CustomFingerConfig{}
+	}
 	if config.NoDefault {
-		resources.EholeData = []byte{}
-		resources.GobyData = []byte{}
-		resources.WappalyzerData = []byte{}
-		resources.FingersHTTPData = []byte{}
-		resources.FingerprinthubWebData = []byte{}
-		if !silent {
-			fmt.Println("[*] 已禁用默认指纹")
-		}
+		log.Infof("已禁用默认指纹")
 	}
 
-	if config.EHole != "" {
-		data, err := loadFingerFile(config.EHole)
-		if err != nil {
-			return fmt.Errorf("加载 EHole 指纹失败: %v", err)
-		}
-		resources.EholeData = data
-		if !silent {
-			fmt.Printf("[*] 已加载自定义 EHole 指纹: %s\n", config.EHole)
-		}
+	files, err := collectRuleFiles(config)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-
-	if config.Goby != "" {
-		data, err := loadFingerFile(config.Goby)
-		if err != nil {
-			return fmt.Errorf("加载 Goby 指纹失败: %v", err)
-		}
-		resources.GobyData = data
-		if !silent {
-			fmt.Printf("[*] 已加载自定义 Goby 指纹: %s\n", config.Goby)
-		}
+	for _, path := range files.skipped {
+		log.Warnf("无法识别指纹格式，已跳过: %s", path)
 	}
 
-	if config.Wappalyzer != "" {
-		data, err := loadFingerFile(config.Wappalyzer)
+	// 合并各格式的默认指纹和自定义指纹
+	data := make(map[string][]byte)
+	for _, f := range engineFormats {
+		merged, loaded, err := loadRuleFormat(f.format, config, files)
 		if err != nil {
-			return fmt.Errorf("加载 Wappalyzer 指纹失败: %v", err)
+			return nil, nil, nil, fmt.Errorf("加载 %s 指纹失败: %v", f.name, err)
+		}
+		if merged != nil {
+			data[f.format] = merged
 		}
-		resources.WappalyzerData = data
-		if !silent {
-			fmt.Printf("[*] 已加载自定义 Wappalyzer 指纹: %s\n", config.Wappalyzer)
+		if len(loaded) > 0 {
+			log.Infof("已加载自定义 %s 指纹: %s", f.name, strings.Join(loaded, ", "))
 		}
 	}
 
-	if config.Fingers != "" {
-		data, err := loadFingerFile(config.Fingers)
-		if err != nil {
-			return fmt.Errorf("加载 Fingers 指纹失败: %v", err)
-		}
-		resources.FingersHTTPData = data
-		if !silent {
-			fmt.Printf("[*] 已加载自定义 Fingers 指纹: %s\n", config.Fingers)
-		}
+	engine, err := newFingersEngine(data)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	if config.FingerPrint != "" {
-		data, err := loadFingerFile(config.FingerPrint)
-		if err != nil {
-			return fmt.Errorf("加载 FingerPrintHub 指纹失败: %v", err)
-		}
-		resources.FingerprinthubWebData = data
-		if !silent {
-			fmt.Printf("[*] 已加载自定义 FingerPrintHub 指纹: %s\n", config.FingerPrint)
+	// ARL 使用独立引擎
+	var arlEngine *ARLEngine
+	merged, loaded, err := loadRuleFormat(FormatARL, config, files)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("加载 ARL 指纹失败: %v", err)
+	}
+	if merged != nil {
+		arlEngine = &ARLEngine{}
+		if err := json.Unmarshal(merged, &arlEngine.fingerprints); err != nil {
+			return nil, nil, nil, fmt.Errorf("加载 ARL 指纹失败: %v", err)
 		}
+		log.Infof("已加载 ARL 指纹: %s (%d 条规则)", strings.Join(append(files.bundled[FormatARL], loaded...), ", "), len(arlEngine.fingerprints))
 	}
 
-	// ARL 使用独立引擎，在 scanner.go 中初始化
+	// JARM 规则由扫描器按 hash 直接查找，合并后随指纹数据返回
+	merged, loaded, err = loadRuleFormat(FormatJARM, config, files)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("加载 JARM 指纹失败: %v", err)
+	}
+	if merged != nil {
+		data[FormatJARM] = merged
+		log.Infof("已加载 JARM 指纹: %s", strings.Join(append(files.bundled[FormatJARM], loaded...), ", "))
+	}
 
-	return nil
+	return engine, arlEngine, data, nil
 }
 
-// loadFingerFile 加载指纹文件
-// 支持 .json 和 .json.gz 格式
+// newFingersEngine 使用给定的指纹数据构建 fingers 引擎
+// 直接调用各格式的引擎构造函数并注册，而不是通过读取 resources 全局数据的 fingers.NewEngine；
+// fingers 库构建 FingerPrintHub 引擎时会直接打印到标准输出，需要屏蔽时使用 Options.RuleLoadHook
 //
 // 参数：
-//   - path: 文件路径
+//   - data: 格式到指纹数据（JSON，可为 gzip 压缩）的映射，缺少的格式不启用
 //
 // 返回：
-//   - []byte: 文件内容（如果是 .json 会自动压缩为 gzip）
-//   - error: 读取错误
-func loadFingerFile(path string) ([]byte, error) {
-	// 读取文件
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
+//   - *fingers.Engine: 编译后的引擎
+//   - error: 初始化错误
+func newFingersEngine(data map[string][]byte) (*fingers.Engine, error) {
+	engine := &fingers.Engine{
+		EnginesImpl:  make(map[string]fingers.EngineImpl),
+		Enabled:      make(map[string]bool),
+		Capabilities: make(map[string]common.EngineCapability),
 	}
+	// favicon 引擎汇总其他引擎的 favicon hash，必须注册
+	engine.Register(favicon.NewFavicons())
 
-	ext := strings.ToLower(filepath.Ext(path))
-
-	// 如果是 .gz 文件，直接返回
-	if ext == ".gz" {
-		return data, nil
-	}
+	for _, f := range engineFormats {
+		d, ok := data[f.format]
+		if !ok {
+			continue
+		}
 
-	// 如果是 .json 文件，需要压缩为 gzip 格式
-	// 因为 fingers 库期望的是 gzip 压缩的数据
-	if ext == ".json" {
-		return gzipCompress(data)
+		var impl fingers.EngineImpl
+		var err error
+		switch f.format {
+		case FormatFingers:
+			impl, err = fingersengine.NewFingersEngine(d, resources.FingersSocketData, resources.PortData)
+		case FormatFingerPrint:
+			// Web 指纹识别只使用 web 模板，不加载 service 模板
+			impl, err = fingerprinthub.NewFingerPrintHubEngine(d, []byte("[]"))
+		case FormatWappalyzer:
+			impl, err = wappalyzer.NewWappalyzeEngine(d)
+		case FormatEHole:
+			impl, err = ehole.NewEHoleEngine(d)
+		case FormatGoby:
+			impl, err = goby.NewGobyEngine(d)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("初始化 %s 引擎失败: %v", f.name, err)
+		}
+		engine.Register(impl)
 	}
 
-	return data, nil
-}
-
-// gzipCompress 将数据压缩为 gzip 格式
-func gzipCompress(data []byte) ([]byte, error) {
-	var buf bytes.Buffer
-	w := gzip.NewWriter(&buf)
-	_, err := w.Write(data)
-	if err != nil {
-		return nil, err
-	}
-	err = w.Close()
-	if err != nil {
+	if err := engine.Compile(); err != nil {
 		return nil, err
 	}
-	return buf.Bytes(), nil
+	return engine, nil
 }
 
-// gzipDecompress 解压 gzip 数据（用于调试）
+// gzipDecompress 解压 gzip 数据
 func gzipDecompress(data []byte) ([]byte, error) {
 	r, err := gzip.NewReader(strings.NewReader(string(data)))
 	if err != nil {
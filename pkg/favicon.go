@@ -0,0 +1,430 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件负责 favicon 的发现、获取和 hash 计算
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spaolacci/murmur3"
+	"golang.org/x/net/html"
+)
+
+// faviconTimeout favicon 及 manifest 请求的超时时间
+// 图标文件一般较小，使用比页面请求更短的超时
+const faviconTimeout = 5 * time.Second
+
+// resourceGetter 获取指定地址的内容
+// pageURL 为引用该资源的页面地址，在线扫描时据此判断资源是否与页面同源；
+// 返回响应体和 Content-Type，在线扫描时发送 HTTP 请求，离线匹配时从已导入的响应中查找
+type resourceGetter func(ctx context.Context, rawURL, pageURL string) ([]byte, string, error)
+
+// httpGetter 返回通过 HTTP 请求获取资源的 resourceGetter
+//
+// 参数：
+//   - client: HTTP 客户端，超时时间一般为 faviconTimeout
+//   - config: 请求配置，跨域的资源不使用其中的认证信息、请求头和 Cookie，见 newResourceRequest
+func httpGetter(client *http.Client, config *RequestConfig) resourceGetter {
+	return func(ctx context.Context, rawURL, pageURL string) ([]byte, string, error) {
+		return httpGet(ctx, client, config, rawURL, pageURL)
+	}
+}
+
+// logGetter 为资源获取函数添加详细调试日志
+func logGetter(log *Logger, get resourceGetter) resourceGetter {
+	if !log.Enabled(LevelTrace) {
+		return get
+	}
+	return func(ctx context.Context, rawURL, pageURL string) ([]byte, string, error) {
+		start := time.Now()
+		data, contentType, err := get(ctx, rawURL, pageURL)
+		if err != nil {
+			log.Tracef("获取资源 %s 失败: %v (%s)", rawURL, err, time.Since(start).Round(time.Millisecond))
+		} else {
+			log.Tracef("获取资源 %s -> %s, %d 字节 (%s)", rawURL, contentType, len(data), time.Since(start).Round(time.Millisecond))
+		}
+		return data, contentType, err
+	}
+}
+
+// iconRels 表示图标的 <link rel> 取值
+// rel 属性按空白拆分后逐个比较，"shortcut icon" 会命中其中的 "icon"
+var iconRels = map[string]bool{
+	"icon":                         true,
+	"apple-touch-icon":             true,
+	"apple-touch-icon-precomposed": true,
+	"mask-icon":                    true,
+	"fluid-icon":                   true,
+}
+
+// extractFaviconLinks 从 HTML 中提取图标和 manifest 地址
+// 使用 HTML 分词器遍历所有 <link> 标签，支持 rel 和 href 任意顺序、
+// 多个图标声明以及 <base href> 基准地址，相对路径基于页面 URL 解析
+//
+// 参数：
+//   - body: HTML 响应体
+//   - pageURL: 当前页面 URL
+//
+// 返回：
+//   - icons: 按文档顺序排列的图标地址
+//   - manifests: web manifest 地址
+func extractFaviconLinks(body, pageURL string) (icons, manifests []string) {
+	page, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, nil
+	}
+
+	// 先收集原始 href，待 <base> 确定后再统一解析
+	var iconHrefs, manifestHrefs []string
+	var baseHref string
+
+	z := html.NewTokenizer(strings.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		name, hasAttr := z.TagName()
+		if !hasAttr {
+			continue
+		}
+
+		var rel, href string
+		for {
+			key, val, more := z.TagAttr()
+			switch string(key) {
+			case "rel":
+				rel = strings.ToLower(string(val))
+			case "href":
+				href = strings.TrimSpace(string(val))
+			}
+			if !more {
+				break
+			}
+		}
+		if href == "" {
+			continue
+		}
+
+		switch string(name) {
+		case "base":
+			// 只有第一个 <base href> 生效
+			if baseHref == "" {
+				baseHref = href
+			}
+		case "link":
+			for _, r := range strings.Fields(rel) {
+				if iconRels[r] {
+					iconHrefs = append(iconHrefs, href)
+					break
+				}
+				if r == "manifest" {
+					manifestHrefs = append(manifestHrefs, href)
+					break
+				}
+			}
+		}
+	}
+
+	// 确定解析相对路径使用的基准地址
+	base := page
+	if baseHref != "" {
+		if b, err := page.Parse(baseHref); err == nil {
+			base = b
+		}
+	}
+
+	for _, href := range iconHrefs {
+		if u := resolveFaviconURL(base, href); u != "" {
+			icons = append(icons, u)
+		}
+	}
+	for _, href := range manifestHrefs {
+		if u := resolveFaviconURL(base, href); u != "" {
+			manifests = append(manifests, u)
+		}
+	}
+	return icons, manifests
+}
+
+// resolveFaviconURL 基于 base 解析图标地址
+// 协议相对地址（//cdn/x.ico）沿用页面协议，仅保留 http/https 地址，
+// data: URI 原样保留，由 fetchFavicon 在本地解码
+func resolveFaviconURL(base *url.URL, href string) string {
+	if isDataURI(href) {
+		return href
+	}
+	u, err := base.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+// defaultFaviconURL 返回站点根目录下的 /favicon.ico 地址
+func defaultFaviconURL(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host + "/favicon.ico"
+}
+
+// webManifest web manifest 文件中与图标相关的字段
+type webManifest struct {
+	Icons []struct {
+		Src string `json:"src"`
+	} `json:"icons"`
+}
+
+// fetchManifestIcons 获取 web manifest 并提取其中声明的图标地址
+// 图标的相对路径基于 manifest 自身的 URL 解析
+//
+// 参数：
+//   - ctx: 上下文
+//   - manifestURL: manifest 文件地址
+//   - pageURL: 引用 manifest 的页面地址
+//   - get: 资源获取函数
+//
+// 返回：
+//   - 图标地址列表
+func fetchManifestIcons(ctx context.Context, manifestURL, pageURL string, get resourceGetter) []string {
+	data, _, err := get(ctx, manifestURL, pageURL)
+	if err != nil {
+		return nil
+	}
+
+	var manifest webManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil
+	}
+
+	base, err := url.Parse(manifestURL)
+	if err != nil {
+		return nil
+	}
+
+	var icons []string
+	for _, icon := range manifest.Icons {
+		if u := resolveFaviconURL(base, strings.TrimSpace(icon.Src)); u != "" {
+			icons = append(icons, u)
+		}
+	}
+	return icons
+}
+
+// fetchFaviconContent 依次尝试候选地址，返回第一个有效图标的内容
+// 顺序为：<link> 声明的图标、manifest 中的图标、/favicon.ico，已尝试的地址不再重复请求；
+// manifest 只在 <link> 图标都无效时才获取，ctx 取消后不再尝试剩余地址
+//
+// 参数：
+//   - ctx: 上下文
+//   - body: HTML 响应体
+//   - pageURL: 当前页面 URL
+//   - get: 资源获取函数
+//
+// 返回：
+//   - 图标内容，全部失败时返回 nil
+func fetchFaviconContent(ctx context.Context, body, pageURL string, get resourceGetter) []byte {
+	tried := make(map[string]bool)
+	try := func(candidates []string) []byte {
+		for _, candidate := range candidates {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if candidate == "" || tried[candidate] {
+				continue
+			}
+			tried[candidate] = true
+			content, err := fetchFavicon(ctx, candidate, pageURL, get)
+			if err == nil && len(content) > 0 {
+				return content
+			}
+		}
+		return nil
+	}
+
+	icons, manifests := extractFaviconLinks(body, pageURL)
+	if content := try(icons); content != nil {
+		return content
+	}
+	for _, m := range manifests {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if content := try(fetchManifestIcons(ctx, m, pageURL, get)); content != nil {
+			return content
+		}
+	}
+	return try([]string{defaultFaviconURL(pageURL)})
+}
+
+// fetchFavicon 获取 favicon 内容
+// 获取 favicon 文件的原始字节内容，并校验其确实为图片
+// data: URI 直接在本地解码，不发送请求
+//
+// 参数：
+//   - ctx: 上下文
+//   - faviconURL: favicon 的完整 URL 或 data: URI
+//   - pageURL: 引用 favicon 的页面地址
+//   - get: 资源获取函数
+//
+// 返回：
+//   - []byte: favicon 文件内容
+//   - error: 错误信息
+func fetchFavicon(ctx context.Context, faviconURL, pageURL string, get resourceGetter) ([]byte, error) {
+	var data []byte
+	var contentType string
+	var err error
+	if isDataURI(faviconURL) {
+		data, contentType, err = decodeDataURI(faviconURL)
+	} else {
+		data, contentType, err = get(ctx, faviconURL, pageURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !isImageContent(data, contentType) {
+		return nil, fmt.Errorf("favicon is not an image: %s", faviconURL)
+	}
+	return data, nil
+}
+
+// isDataURI 判断地址是否为 data: URI
+func isDataURI(s string) bool {
+	return len(s) > 5 && strings.EqualFold(s[:5], "data:")
+}
+
+// decodeDataURI 解码 data: URI
+// 格式为 data:[<mediatype>][;base64],<data>，
+// 非 base64 内容（常见于内联 SVG）按百分号编码解码
+//
+// 参数：
+//   - uri: data: URI
+//
+// 返回：
+//   - []byte: 解码后的内容
+//   - string: 媒体类型，未声明时为空
+//   - error: 格式错误
+func decodeDataURI(uri string) ([]byte, string, error) {
+	comma := strings.IndexByte(uri, ',')
+	if comma < 0 {
+		return nil, "", fmt.Errorf("invalid data uri: missing comma")
+	}
+	meta := uri[5:comma]
+	payload := uri[comma+1:]
+
+	isBase64 := false
+	params := strings.Split(meta, ";")
+	for _, p := range params[1:] {
+		if strings.EqualFold(strings.TrimSpace(p), "base64") {
+			isBase64 = true
+		}
+	}
+	mediaType := strings.TrimSpace(params[0])
+
+	// 内容可能带有百分号编码
+	if unescaped, err := url.PathUnescape(payload); err == nil {
+		payload = unescaped
+	}
+
+	if !isBase64 {
+		return []byte(payload), mediaType, nil
+	}
+
+	// 去掉 HTML 中常见的换行和空白
+	payload = strings.Join(strings.Fields(payload), "")
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(payload, "="))
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid data uri: %v", err)
+		}
+	}
+	return data, mediaType, nil
+}
+
+// httpGet 发送 GET 请求并返回响应体和 Content-Type
+// 与页面同源时请求头、Cookie 和 User-Agent 与页面请求一致，非 200 状态码视为失败
+func httpGet(ctx context.Context, client *http.Client, config *RequestConfig, rawURL, pageURL string) ([]byte, string, error) {
+	req, err := config.newResourceRequest(ctx, rawURL, pageURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, "", fmt.Errorf("request failed: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// isImageContent 判断响应内容是否为图片
+// 很多站点对不存在的 /favicon.ico 返回 200 的 HTML 页面，需要排除
+func isImageContent(data []byte, contentType string) bool {
+	if len(data) == 0 {
+		return false
+	}
+
+	sniffed := http.DetectContentType(data)
+	if strings.HasPrefix(sniffed, "text/html") {
+		return false
+	}
+	if strings.HasPrefix(strings.ToLower(contentType), "image/") || strings.HasPrefix(sniffed, "image/") {
+		return true
+	}
+
+	// SVG 会被识别为 text/xml 或 text/plain
+	head := data
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	return bytes.Contains(bytes.ToLower(head), []byte("<svg"))
+}
+
+// calcFaviconHash 计算 favicon 的 MMH3 hash
+// 使用与 Shodan 相同的算法：base64 编码后计算 murmur3 hash
+func calcFaviconHash(data []byte) string {
+	// Base64 编码
+	b64 := base64.StdEncoding.EncodeToString(data)
+	// 按 76 字符换行（标准 base64 格式）
+	var buf bytes.Buffer
+	for i := 0; i < len(b64); i += 76 {
+		end := i + 76
+		if end > len(b64) {
+			end = len(b64)
+		}
+		buf.WriteString(b64[i:end])
+		buf.WriteString("\n")
+	}
+	// 计算 murmur3 hash
+	hash := murmur3.Sum32(buf.Bytes())
+	return strconv.FormatInt(int64(int32(hash)), 10)
+}
@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// TestFetchFaviconContentOrder 依次尝试 <link> 图标、manifest 图标和 /favicon.ico，manifest 只在 <link> 图标都无效时获取
+func TestFetchFaviconContentOrder(t *testing.T) {
+	const page = "http://example.com/app/"
+	const body = `<link rel="icon" href="/a.png"><link rel="manifest" href="/site.webmanifest"><link rel="shortcut icon" href="/b.png">`
+	const png = "\x89PNG\r\n\x1a\n"
+
+	tests := []struct {
+		name      string
+		resources map[string]string
+		want      string
+		requested []string
+	}{
+		{
+			name:      "link icon",
+			resources: map[string]string{"http://example.com/b.png": "b"},
+			want:      "b",
+			requested: []string{"http://example.com/a.png", "http://example.com/b.png"},
+		},
+		{
+			name: "manifest icon",
+			resources: map[string]string{
+				"http://example.com/site.webmanifest": `{"icons": [{"src": "/a.png"}, {"src": "icons/c.png"}]}`,
+				"http://example.com/icons/c.png":      "c",
+			},
+			want:      "c",
+			requested: []string{"http://example.com/a.png", "http://example.com/b.png", "http://example.com/site.webmanifest", "http://example.com/icons/c.png"},
+		},
+		{
+			name:      "default favicon",
+			resources: map[string]string{"http://example.com/favicon.ico": "ico"},
+			want:      "ico",
+			requested: []string{"http://example.com/a.png", "http://example.com/b.png", "http://example.com/site.webmanifest", "http://example.com/favicon.ico"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested []string
+			get := func(ctx context.Context, rawURL, pageURL string) ([]byte, string, error) {
+				requested = append(requested, rawURL)
+				if pageURL != page {
+					t.Errorf("pageURL = %q, want %q", pageURL, page)
+				}
+				content, ok := tt.resources[rawURL]
+				if !ok {
+					return nil, "", fmt.Errorf("not found: %s", rawURL)
+				}
+				if rawURL == "http://example.com/site.webmanifest" {
+					return []byte(content), "application/manifest+json", nil
+				}
+				return []byte(png + content), "image/png", nil
+			}
+
+			got := fetchFaviconContent(context.Background(), body, page, get)
+			if want := png + tt.want; string(got) != want {
+				t.Errorf("content = %q, want %q", got, want)
+			}
+			if !reflect.DeepEqual(requested, tt.requested) {
+				t.Errorf("requested = %v, want %v", requested, tt.requested)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件实现单个响应的同步指纹识别，供代理插件等场景使用，不发送任何网络请求
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// FingerprintRequest 单个响应的指纹识别请求
+// Raw 与 URL + StatusCode + Headers + Body 二选一，Raw 不为空时忽略后者
+type FingerprintRequest struct {
+	URL         string            `json:"url"`          // 响应对应的 URL
+	Raw         string            `json:"raw"`          // 原始 HTTP 响应报文
+	StatusCode  int               `json:"status_code"`  // 状态码，默认 200
+	Headers     map[string]string `json:"headers"`      // 响应头
+	Body        string            `json:"body"`         // 响应体
+	Favicon     []byte            `json:"favicon"`      // favicon 内容，JSON 中为 base64
+	FaviconHash string            `json:"favicon_hash"` // favicon 的 MMH3 hash，没有 favicon 内容时使用，无符号形式按有符号形式匹配
+}
+
+// Fingerprint 对单个响应进行指纹识别
+// 依次执行 fingers 引擎匹配、ARL 匹配和 favicon 匹配，与扫描时的识别流程一致，但不发送任何请求：
+// favicon 只使用请求中提供的内容或 hash，都没有时从页面的 data: URI 图标中解码
+//
+// 参数：
+//   - ctx: 上下文，取消后 ARL 匹配提前结束
+//   - req: 识别请求
+//
+// 返回：
+//   - Result: 识别结果
+//   - error: 响应解析错误
+func (s *Scanner) Fingerprint(ctx context.Context, req FingerprintRequest) (Result, error) {
+	resp, err := req.response()
+	if err != nil {
+		return Result{}, err
+	}
+
+	favicon := req.Favicon
+	// 与 ARL 指纹一致，无符号形式和带前导零的 hash 转为 favicon 计算使用的有符号形式
+	faviconHash := normalizeMMH3(strings.TrimSpace(req.FaviconHash))
+	if len(favicon) == 0 && faviconHash == "" {
+		favicon = fetchFaviconContent(ctx, resp.Body, resp.URL, offlineGetter(nil))
+	}
+	if len(favicon) > 0 {
+		faviconHash = calcFaviconHash(favicon)
+	}
+
+	return newResult(resp, s.matchResponse(ctx, resp, favicon, faviconHash)), nil
+}
+
+// response 将识别请求转换为解析后的响应
+func (req FingerprintRequest) response() (*Response, error) {
+	if req.Raw != "" {
+		return parseRawResponse([]byte(req.Raw), req.URL)
+	}
+
+	code := req.StatusCode
+	if code == 0 {
+		code = http.StatusOK
+	}
+	if code < 100 || code > 999 {
+		return nil, fmt.Errorf("无效的状态码: %d", code)
+	}
+
+	header := make(http.Header, len(req.Headers))
+	for k, v := range req.Headers {
+		header.Set(k, v)
+	}
+	resp := &http.Response{
+		StatusCode: code,
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+	}
+	return parseResponse([]string{req.URL, "1"}, resp, []byte(req.Body)), nil
+}
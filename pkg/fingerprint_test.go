@@ -0,0 +1,41 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+)
+
+// TestFingerprintFaviconHash 请求中的 favicon hash 与规则一样按有符号形式匹配
+func TestFingerprintFaviconHash(t *testing.T) {
+	path := writeTemp(t, "arl.yaml", `
+- name: Signed_icon_hash
+  rule: icon_hash="-1214117000"
+- name: Unsigned_icon_hash
+  rule: icon_hash="116323821"
+`)
+	s, err := New(Options{Rules: &CustomFingerConfig{ARL: []string{path}, NoDefault: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		hash string
+		want string
+	}{
+		{"-1214117000", "Signed"},
+		{"3080850296", "Signed"},
+		{" -01214117000 ", "Signed"},
+		{"0116323821", "Unsigned"},
+		{"116323821", "Unsigned"},
+		{"12345", ""},
+	}
+	for _, tt := range tests {
+		result, err := s.Fingerprint(context.Background(), FingerprintRequest{URL: "http://example.com/", FaviconHash: tt.hash})
+		if err != nil {
+			t.Fatalf("Fingerprint(%q): %v", tt.hash, err)
+		}
+		if result.CMS != tt.want {
+			t.Errorf("Fingerprint(%q) CMS = %q, want %q", tt.hash, result.CMS, tt.want)
+		}
+	}
+}
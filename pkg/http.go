@@ -4,25 +4,17 @@ package pkg
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
-	"encoding/base64"
 	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
-	"net/url"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
-
-	"github.com/spaolacci/murmur3"
 )
 
-// Timeout 请求超时时间（秒）
-// 可通过命令行参数修改
-var Timeout = 10
-
 // Response HTTP 响应结构体
 // 包含 HTTP 响应的所有关键信息，供指纹识别使用
 type Response struct {
@@ -36,6 +28,13 @@ type Response struct {
 	Length     int                 // 响应体长度
 	Title      string              // 页面标题（从 <title> 标签提取）
 	JsURLs     []string            // JS 跳转 URL 列表
+	TLS        *TLSInfo            // TLS 连接信息和服务器证书，非 HTTPS 响应为 nil
+	IPs        []string            // 目标域名解析到的 IP
+	JARM       string              // 目标的 JARM hash，只在开启 JARM 探测时设置
+	CDN        *CDNInfo            // CDN 信息，只在开启 CDN 检测时设置
+	WAF        *WAFInfo            // WAF 信息，只在开启 WAF 检测时设置
+	Proto      string              // 响应的 HTTP 版本，如 HTTP/1.1
+	Protocol   *ProtocolInfo       // 目标支持的 HTTP 协议，只在开启协议检测时设置
 }
 
 // userAgents 常用浏览器 User-Agent 列表
@@ -107,51 +106,58 @@ func buildRawResponse(resp *http.Response, body []byte) []byte {
 	return buf.Bytes()
 }
 
-// fetch 发送 HTTP 请求并解析响应
-// 这是核心的 HTTP 请求函数，负责：
-// 1. 创建 HTTP 客户端（支持代理和 TLS）
-// 2. 发送请求并读取响应
-// 3. 解析响应内容（编码转换、标题提取等）
-// 4. 构建原始响应供 fingers 引擎使用
+// newHTTPClient 创建 HTTP 客户端
+// 跳过 TLS 证书验证，通过 resolver 解析目标域名，并按需通过代理池发送请求
 //
 // 参数：
-//   - task: 任务数组，task[0] 为 URL，task[1] 为任务类型（"0" 表示主页面，"1" 表示 JS 跳转页面）
-//   - proxy: 代理地址，为空则不使用代理
+//   - proxies: 代理池，为 nil 则不使用代理
+//   - resolver: DNS 解析器，为 nil 则使用系统 DNS
+//   - timeout: 请求超时时间
+//   - configure: 对传输层的额外配置（如 TLS SNI、HTTP/2），在配置代理之前执行
 //
 // 返回：
-//   - *Response: 解析后的响应结构体
-//   - error: 错误信息
-func fetch(task []string, proxy string) (*Response, error) {
+//   - *http.Client: HTTP 客户端
+func newHTTPClient(proxies *ProxyPool, resolver *Resolver, timeout time.Duration, configure ...func(*http.Transport)) *http.Client {
 	// 创建 HTTP 传输层，跳过 TLS 证书验证
 	transport := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 	}
-
-	// 配置代理
-	if proxy != "" {
-		proxyURL, _ := url.Parse(proxy)
-		transport.Proxy = http.ProxyURL(proxyURL)
+	if resolver != nil {
+		transport.DialContext = resolver.DialContext
+	}
+	for _, f := range configure {
+		f(transport)
 	}
 
-	// 创建 HTTP 客户端
-	client := &http.Client{
-		Timeout:   time.Duration(Timeout) * time.Second,
-		Transport: transport,
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: proxies.roundTripper(transport, resolver),
 	}
+}
 
-	// 创建请求
-	req, err := http.NewRequest("GET", task[0], nil)
+// fetch 发送 HTTP 请求并解析响应
+// 这是核心的 HTTP 请求函数，负责：
+// 1. 使用扫描器的 HTTP 客户端（支持代理和 TLS）
+// 2. 按请求配置发送请求并读取响应
+// 3. 解析响应内容（编码转换、标题提取等）
+// 4. 构建原始响应供 fingers 引擎使用
+//
+// 参数：
+//   - ctx: 上下文，取消后请求立即中止
+//   - client: HTTP 客户端
+//   - config: 请求配置，为 nil 时使用默认配置
+//   - task: 任务数组，task[0] 为 URL，task[1] 为任务类型（"0" 表示主页面，"1" 表示 JS 跳转页面）
+//
+// 返回：
+//   - *Response: 解析后的响应结构体
+//   - error: 错误信息
+func fetch(ctx context.Context, client *http.Client, config *RequestConfig, task []string) (*Response, error) {
+	// 按配置创建请求，默认携带 rememberMe cookie 用于检测 Shiro 框架
+	req, err := config.newRequest(ctx, task[0])
 	if err != nil {
 		return nil, err
 	}
 
-	// 设置请求头
-	// 添加 rememberMe cookie 用于检测 Shiro 框架
-	req.AddCookie(&http.Cookie{Name: "rememberMe", Value: "me"})
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Connection", "close")
-	req.Header.Set("User-Agent", randomUA())
-
 	// 发送请求
 	resp, err := client.Do(req)
 	if err != nil {
@@ -162,6 +168,20 @@ func fetch(task []string, proxy string) (*Response, error) {
 	// 读取原始响应体
 	rawBody, _ := io.ReadAll(resp.Body)
 
+	return parseResponse(task, resp, rawBody), nil
+}
+
+// parseResponse 将 HTTP 响应解析为 Response 结构体
+// 在线请求和离线导入的响应共用此函数，保证两者的解析结果一致
+//
+// 参数：
+//   - task: 任务数组，task[0] 为 URL，task[1] 为任务类型
+//   - resp: HTTP 响应对象，只使用状态码、协议版本和响应头
+//   - rawBody: 原始响应体
+//
+// 返回：
+//   - *Response: 解析后的响应结构体
+func parseResponse(task []string, resp *http.Response, rawBody []byte) *Response {
 	// 构建原始 HTTP 响应（供 fingers 引擎使用）
 	rawContent := buildRawResponse(resp, rawBody)
 
@@ -179,7 +199,7 @@ func fetch(task []string, proxy string) (*Response, error) {
 
 	// 解析 JS 跳转（仅对主页面进行）
 	var jsURLs []string
-	if task[1] == "0" {
+	if task[1] == "0" || task[1] == taskCert {
 		jsURLs = parseJSRedirect(body, task[0])
 	}
 
@@ -205,124 +225,16 @@ func fetch(task []string, proxy string) (*Response, error) {
 		Length:     len(body),
 		Title:      extractTitle(body),
 		JsURLs:     jsURLs,
-	}, nil
-}
-
-// extractFaviconURL 从 HTML 中提取 favicon URL
-// 查找 <link rel="icon" href="xxx"> 或 <link rel="shortcut icon" href="xxx">
-//
-// 参数：
-//   - body: HTML 响应体
-//   - baseURL: 当前页面 URL，用于构建完整的 favicon URL
-//
-// 返回：
-//   - favicon 的完整 URL
-func extractFaviconURL(body, baseURL string) string {
-	// 解析基础 URL
-	u, err := url.Parse(baseURL)
-	if err != nil {
-		return ""
-	}
-	base := u.Scheme + "://" + u.Host
-
-	// 尝试从 HTML 中提取 favicon 路径
-	// 匹配 <link rel="icon" href="xxx"> 或 <link rel="shortcut icon" href="xxx">
-	patterns := []string{
-		`<link[^>]*rel=["'](?:shortcut )?icon["'][^>]*href=["']([^"']+)["']`,
-		`<link[^>]*href=["']([^"']+)["'][^>]*rel=["'](?:shortcut )?icon["']`,
-		`href=["']([^"']*favicon[^"']*)["']`,
-	}
-
-	for _, pattern := range patterns {
-		re := regexp.MustCompile(`(?i)` + pattern)
-		match := re.FindStringSubmatch(body)
-		if len(match) > 1 {
-			faviconPath := match[1]
-			// 处理不同格式的路径
-			if strings.HasPrefix(faviconPath, "//") {
-				return "http:" + faviconPath
-			} else if strings.HasPrefix(faviconPath, "http") {
-				return faviconPath
-			} else if strings.HasPrefix(faviconPath, "/") {
-				return base + faviconPath
-			} else {
-				return base + "/" + faviconPath
-			}
-		}
-	}
-
-	// 默认使用 /favicon.ico
-	return base + "/favicon.ico"
-}
-
-// fetchFavicon 获取 favicon 内容
-// 发送 HTTP 请求获取 favicon 文件的原始字节内容
-//
-// 参数：
-//   - faviconURL: favicon 的完整 URL
-//   - proxy: 代理地址，为空则不使用代理
-//
-// 返回：
-//   - []byte: favicon 文件内容
-//   - error: 错误信息
-func fetchFavicon(faviconURL, proxy string) ([]byte, error) {
-	// 创建 HTTP 传输层
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-
-	// 配置代理
-	if proxy != "" {
-		proxyURL, _ := url.Parse(proxy)
-		transport.Proxy = http.ProxyURL(proxyURL)
+		TLS:        newTLSInfo(resp.TLS),
+		Proto:      resp.Proto,
 	}
-
-	// 创建 HTTP 客户端，使用较短的超时时间
-	client := &http.Client{
-		Timeout:   time.Duration(5) * time.Second,
-		Transport: transport,
-	}
-
-	// 创建请求
-	req, err := http.NewRequest("GET", faviconURL, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("User-Agent", randomUA())
-
-	// 发送请求
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	// 检查状态码
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("favicon request failed: %d", resp.StatusCode)
-	}
-
-	// 读取响应体
-	return io.ReadAll(resp.Body)
 }
 
-// calcFaviconHash 计算 favicon 的 MMH3 hash
-// 使用与 Shodan 相同的算法：base64 编码后计算 murmur3 hash
-func calcFaviconHash(data []byte) string {
-	// Base64 编码
-	b64 := base64.StdEncoding.EncodeToString(data)
-	// 按 76 字符换行（标准 base64 格式）
-	var buf bytes.Buffer
-	for i := 0; i < len(b64); i += 76 {
-		end := i + 76
-		if end > len(b64) {
-			end = len(b64)
-		}
-		buf.WriteString(b64[i:end])
-		buf.WriteString("\n")
+// RawBody 返回原始响应体（未做编码转换）
+// 从 RawContent 中截取，避免重复保存响应体
+func (r *Response) RawBody() []byte {
+	if i := bytes.Index(r.RawContent, []byte("\r\n\r\n")); i >= 0 {
+		return r.RawContent[i+4:]
 	}
-	// 计算 murmur3 hash
-	hash := murmur3.Sum32(buf.Bytes())
-	return strconv.FormatInt(int64(int32(hash)), 10)
+	return nil
 }
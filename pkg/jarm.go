@@ -0,0 +1,543 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件实现 JARM TLS 服务端指纹：
+// 1. 向目标发送 10 个参数各不相同的 TLS ClientHello（TLS 版本、加密套件顺序、GREASE、ALPN、扩展顺序）
+// 2. 记录每次 ServerHello 选择的加密套件、版本和扩展，按 JARM 算法生成 62 位 hash
+// 3. 与 JARM 规则（hash -> 名称）比对，识别 TLS 服务端实现和 C2 框架
+//
+// 算法与 salesforce/jarm 一致，相同的服务端得到相同的 hash，可以直接使用公开的 JARM 情报
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// jarmProbe 单个 JARM 探测的 ClientHello 参数
+type jarmProbe struct {
+	version  string // TLS 版本：1.1、1.2、1.3
+	ciphers  string // 加密套件列表：ALL 或 NO1.3（不含 TLS 1.3 套件）
+	order    string // 加密套件顺序：FORWARD、REVERSE、TOP_HALF、BOTTOM_HALF、MIDDLE_OUT
+	grease   bool   // 是否加入 GREASE 值
+	rareALPN bool   // 是否只提供少见的 ALPN（不含 h2 和 http/1.1）
+	support  string // supported_versions 扩展：1.2_SUPPORT、1.3_SUPPORT、NO_SUPPORT（不发送）
+	extOrder string // ALPN 和 supported_versions 的顺序
+}
+
+// jarmProbes JARM 的 10 个探测，顺序影响 hash，不能调整
+var jarmProbes = []jarmProbe{
+	{"1.2", "ALL", "FORWARD", false, false, "1.2_SUPPORT", "REVERSE"},
+	{"1.2", "ALL", "REVERSE", false, false, "1.2_SUPPORT", "FORWARD"},
+	{"1.2", "ALL", "TOP_HALF", false, false, "NO_SUPPORT", "FORWARD"},
+	{"1.2", "ALL", "BOTTOM_HALF", false, true, "NO_SUPPORT", "FORWARD"},
+	{"1.2", "ALL", "MIDDLE_OUT", true, true, "NO_SUPPORT", "REVERSE"},
+	{"1.1", "ALL", "FORWARD", false, false, "NO_SUPPORT", "FORWARD"},
+	{"1.3", "ALL", "FORWARD", false, false, "1.3_SUPPORT", "REVERSE"},
+	{"1.3", "ALL", "REVERSE", false, false, "1.3_SUPPORT", "FORWARD"},
+	{"1.3", "NO1.3", "FORWARD", false, false, "1.3_SUPPORT", "FORWARD"},
+	{"1.3", "ALL", "MIDDLE_OUT", true, false, "1.3_SUPPORT", "REVERSE"},
+}
+
+// jarmCiphers 探测中提供的加密套件（ALL），NO1.3 去掉其中的 0x13xx
+var jarmCiphers = []uint16{
+	0x0016, 0x0033, 0x0067, 0xc09e, 0xc0a2, 0x009e, 0x0039, 0x006b,
+	0xc09f, 0xc0a3, 0x009f, 0x0045, 0x00be, 0x0088, 0x00c4, 0x009a,
+	0xc008, 0xc009, 0xc023, 0xc0ac, 0xc0ae, 0xc02b, 0xc00a, 0xc024,
+	0xc0ad, 0xc0af, 0xc02c, 0xc072, 0xc073, 0xcca9, 0x1302, 0x1301,
+	0xcc14, 0xc007, 0xc012, 0xc013, 0xc027, 0xc02f, 0xc014, 0xc028,
+	0xc030, 0xc060, 0xc061, 0xc076, 0xc077, 0xcca8, 0x1305, 0x1304,
+	0x1303, 0xcc13, 0xc011, 0x000a, 0x002f, 0x003c, 0xc09c, 0xc0a0,
+	0x009c, 0x0035, 0x003d, 0xc09d, 0xc0a1, 0x009d, 0x0041, 0x00ba,
+	0x0084, 0x00c0, 0x0007, 0x0004, 0x0005,
+}
+
+// jarmCipherIndex 生成 hash 时加密套件的编号顺序
+var jarmCipherIndex = []string{
+	"0004", "0005", "0007", "000a", "0016", "002f", "0033", "0035", "0039", "003c", "003d", "0041", "0045", "0067", "006b",
+	"0084", "0088", "009a", "009c", "009d", "009e", "009f", "00ba", "00be", "00c0", "00c4", "c007", "c008", "c009", "c00a",
+	"c011", "c012", "c013", "c014", "c023", "c024", "c027", "c028", "c02b", "c02c", "c02f", "c030", "c060", "c061", "c072",
+	"c073", "c076", "c077", "c09c", "c09d", "c09e", "c09f", "c0a0", "c0a1", "c0a2", "c0a3", "c0ac", "c0ad", "c0ae", "c0af",
+	"cc13", "cc14", "cca8", "cca9", "1301", "1302", "1303", "1304", "1305",
+}
+
+// jarmALPNs 探测中提供的 ALPN，从弱到强排列
+var jarmALPNs = []string{"http/0.9", "http/1.0", "http/1.1", "spdy/1", "spdy/2", "spdy/3", "h2", "h2c", "hq"}
+
+// jarmRareALPNs 少见的 ALPN，去掉 h2 和 http/1.1
+var jarmRareALPNs = []string{"http/0.9", "http/1.0", "spdy/1", "spdy/2", "spdy/3", "h2c", "hq"}
+
+// jarmReadLimit 每个探测最多读取的响应字节数，与参考实现一致
+const jarmReadLimit = 1484
+
+// jarmEmpty 所有探测都没有得到 ServerHello 时的原始结果
+const jarmEmpty = "|||,|||,|||,|||,|||,|||,|||,|||,|||,|||"
+
+// JARM 计算目标的 JARM hash
+//
+// 参数：
+//   - ctx: 上下文，取消后停止探测
+//   - host: 目标主机名或 IP，同时作为 SNI
+//   - port: 目标端口
+//   - timeout: 每个探测的连接和读取超时时间
+//
+// 返回：
+//   - string: 62 位 JARM hash，目标不支持 TLS 时为 62 个 0
+//   - error: 连接超时或 ctx 取消
+func JARM(ctx context.Context, host, port string, timeout time.Duration) (string, error) {
+	return jarmWithDialer(ctx, (&net.Dialer{}).DialContext, host, port, timeout)
+}
+
+// jarmDialFunc 建立 TCP 连接的函数，与 net.Dialer.DialContext 签名一致
+type jarmDialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// jarmWithDialer 使用指定的拨号函数计算 JARM hash
+func jarmWithDialer(ctx context.Context, dial jarmDialFunc, host, port string, timeout time.Duration) (string, error) {
+	addr := net.JoinHostPort(host, port)
+	parts := make([]string, 0, len(jarmProbes))
+	for _, probe := range jarmProbes {
+		data, err := jarmSend(ctx, dial, addr, jarmClientHello(probe, host), timeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			// 与参考实现一致：任何一个探测超时都视为目标无法探测
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return "", err
+			}
+		}
+		parts = append(parts, jarmParseServerHello(data))
+	}
+	return jarmHash(strings.Join(parts, ",")), nil
+}
+
+// jarmSend 发送一个 ClientHello 并读取服务端的第一个 TLS 记录
+// 连接被拒绝、重置等错误返回 nil 数据（该探测记为空），超时返回错误
+func jarmSend(ctx context.Context, dial jarmDialFunc, addr string, hello []byte, timeout time.Duration) ([]byte, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	conn, err := dial(dialCtx, "tcp", addr)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write(hello); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, jarmReadLimit)
+	n := 0
+	for n < len(buf) {
+		m, err := conn.Read(buf[n:])
+		n += m
+		// 读完第一个记录即可，ServerHello 总在第一个记录中
+		if n >= 5 && n >= 5+int(buf[3])<<8|int(buf[4]) {
+			break
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() && n > 0 {
+				break
+			}
+			return nil, err
+		}
+	}
+	return buf[:n], nil
+}
+
+// jarmClientHello 按探测参数构造完整的 TLS 记录
+func jarmClientHello(p jarmProbe, host string) []byte {
+	recordVersion, helloVersion := uint16(0x0303), uint16(0x0303)
+	switch p.version {
+	case "1.3":
+		recordVersion = 0x0301
+	case "1.1":
+		recordVersion, helloVersion = 0x0302, 0x0302
+	}
+
+	hello := appendUint16(nil, helloVersion)
+	hello = append(hello, jarmRandom(32)...)
+	hello = append(hello, 32)
+	hello = append(hello, jarmRandom(32)...)
+	ciphers := jarmCipherList(p)
+	hello = appendUint16(hello, uint16(len(ciphers)))
+	hello = append(hello, ciphers...)
+	hello = append(hello, 0x01, 0x00) // 压缩方法：null
+	hello = append(hello, jarmExtensions(p, host)...)
+
+	handshake := []byte{0x01, 0x00}
+	handshake = appendUint16(handshake, uint16(len(hello)))
+	handshake = append(handshake, hello...)
+
+	record := []byte{0x16}
+	record = appendUint16(record, recordVersion)
+	record = appendUint16(record, uint16(len(handshake)))
+	return append(record, handshake...)
+}
+
+// jarmCipherList 按探测参数生成加密套件列表
+func jarmCipherList(p jarmProbe) []byte {
+	var list [][]byte
+	for _, c := range jarmCiphers {
+		if p.ciphers == "NO1.3" && c>>8 == 0x13 {
+			continue
+		}
+		list = append(list, appendUint16(nil, c))
+	}
+	list = jarmReorder(list, p.order)
+	if p.grease {
+		list = append([][]byte{jarmGrease()}, list...)
+	}
+	return joinBytes(list)
+}
+
+// jarmExtensions 按探测参数生成扩展列表（包含 2 字节长度）
+func jarmExtensions(p jarmProbe, host string) []byte {
+	var ext []byte
+	if p.grease {
+		ext = append(ext, jarmGrease()...)
+		ext = append(ext, 0x00, 0x00)
+	}
+
+	// server_name
+	ext = append(ext, 0x00, 0x00)
+	ext = appendUint16(ext, uint16(len(host)+5))
+	ext = appendUint16(ext, uint16(len(host)+3))
+	ext = append(ext, 0x00)
+	ext = appendUint16(ext, uint16(len(host)))
+	ext = append(ext, host...)
+
+	ext = append(ext, 0x00, 0x17, 0x00, 0x00)                                                             // extended_master_secret
+	ext = append(ext, 0x00, 0x01, 0x00, 0x01, 0x01)                                                       // max_fragment_length
+	ext = append(ext, 0xff, 0x01, 0x00, 0x01, 0x00)                                                       // renegotiation_info
+	ext = append(ext, 0x00, 0x0a, 0x00, 0x0a, 0x00, 0x08, 0x00, 0x1d, 0x00, 0x17, 0x00, 0x18, 0x00, 0x19) // supported_groups
+	ext = append(ext, 0x00, 0x0b, 0x00, 0x02, 0x01, 0x00)                                                 // ec_point_formats
+	ext = append(ext, 0x00, 0x23, 0x00, 0x00)                                                             // session_ticket
+	ext = append(ext, jarmALPN(p)...)
+	ext = append(ext, 0x00, 0x0d, 0x00, 0x14, 0x00, 0x12, 0x04, 0x03, 0x08, 0x04, 0x04, 0x01, // signature_algorithms
+		0x05, 0x03, 0x08, 0x05, 0x05, 0x01, 0x08, 0x06, 0x06, 0x01, 0x02, 0x01)
+	ext = append(ext, jarmKeyShare(p.grease)...)
+	ext = append(ext, 0x00, 0x2d, 0x00, 0x02, 0x01, 0x01) // psk_key_exchange_modes
+	if p.version == "1.3" || p.support == "1.2_SUPPORT" {
+		ext = append(ext, jarmSupportedVersions(p)...)
+	}
+
+	return append(appendUint16(nil, uint16(len(ext))), ext...)
+}
+
+// jarmALPN 生成 ALPN 扩展
+func jarmALPN(p jarmProbe) []byte {
+	names := jarmALPNs
+	if p.rareALPN {
+		names = jarmRareALPNs
+	}
+	var list [][]byte
+	for _, name := range names {
+		list = append(list, append([]byte{byte(len(name))}, name...))
+	}
+	alpns := joinBytes(jarmReorder(list, p.extOrder))
+
+	ext := []byte{0x00, 0x10}
+	ext = appendUint16(ext, uint16(len(alpns)+2))
+	ext = appendUint16(ext, uint16(len(alpns)))
+	return append(ext, alpns...)
+}
+
+// jarmKeyShare 生成 key_share 扩展（x25519，随机公钥）
+func jarmKeyShare(grease bool) []byte {
+	var share []byte
+	if grease {
+		share = append(share, jarmGrease()...)
+		share = append(share, 0x00, 0x01, 0x00)
+	}
+	share = append(share, 0x00, 0x1d, 0x00, 0x20)
+	share = append(share, jarmRandom(32)...)
+
+	ext := []byte{0x00, 0x33}
+	ext = appendUint16(ext, uint16(len(share)+2))
+	ext = appendUint16(ext, uint16(len(share)))
+	return append(ext, share...)
+}
+
+// jarmSupportedVersions 生成 supported_versions 扩展
+func jarmSupportedVersions(p jarmProbe) []byte {
+	list := [][]byte{{0x03, 0x01}, {0x03, 0x02}, {0x03, 0x03}}
+	if p.support != "1.2_SUPPORT" {
+		list = append(list, []byte{0x03, 0x04})
+	}
+	list = jarmReorder(list, p.extOrder)
+
+	var versions []byte
+	if p.grease {
+		versions = append(versions, jarmGrease()...)
+	}
+	versions = append(versions, joinBytes(list)...)
+
+	ext := []byte{0x00, 0x2b}
+	ext = appendUint16(ext, uint16(len(versions)+1))
+	ext = append(ext, byte(len(versions)))
+	return append(ext, versions...)
+}
+
+// jarmReorder 按 JARM 的顺序规则重新排列列表
+func jarmReorder(list [][]byte, order string) [][]byte {
+	n := len(list)
+	switch order {
+	case "REVERSE":
+		out := make([][]byte, n)
+		for i, item := range list {
+			out[n-1-i] = item
+		}
+		return out
+	case "BOTTOM_HALF":
+		if n%2 == 1 {
+			return list[n/2+1:]
+		}
+		return list[n/2:]
+	case "TOP_HALF":
+		var out [][]byte
+		if n%2 == 1 {
+			out = append(out, list[n/2])
+		}
+		return append(out, jarmReorder(jarmReorder(list, "REVERSE"), "BOTTOM_HALF")...)
+	case "MIDDLE_OUT":
+		var out [][]byte
+		middle := n / 2
+		if n%2 == 1 {
+			out = append(out, list[middle])
+			for i := 1; i <= middle; i++ {
+				out = append(out, list[middle+i], list[middle-i])
+			}
+		} else {
+			for i := 1; i <= middle; i++ {
+				out = append(out, list[middle-1+i], list[middle-i])
+			}
+		}
+		return out
+	}
+	return list
+}
+
+// jarmParseServerHello 从服务端响应中提取 "加密套件|版本|ALPN|扩展列表"
+// 不是 ServerHello（如 TLS 告警、非 TLS 服务）时返回 "|||"
+func jarmParseServerHello(data []byte) string {
+	if len(data) < 44 || data[0] != 0x16 || data[5] != 0x02 {
+		return "|||"
+	}
+	helloLen := int(data[3])<<8 | int(data[4])
+	counter := int(data[43])
+	if len(data) < counter+46 {
+		return "|||"
+	}
+	cipher := hex.EncodeToString(data[counter+44 : counter+46])
+	version := hex.EncodeToString(data[9:11])
+	return cipher + "|" + version + "|" + jarmExtensionInfo(data, counter, helloLen)
+}
+
+// jarmExtensionInfo 提取 ServerHello 中选择的 ALPN 和扩展类型列表，格式为 "ALPN|类型-类型"
+func jarmExtensionInfo(data []byte, counter, helloLen int) string {
+	if len(data) <= counter+47 || data[counter+47] == 11 {
+		return "|"
+	}
+	if bytesAt(data, counter+50, 0x0e, 0xac, 0x0b) || bytesAt(data, 82, 0x0f, 0xf0, 0x0b) || counter+42 >= helloLen {
+		return "|"
+	}
+
+	count := 49 + counter
+	if len(data) < count {
+		return "|"
+	}
+	maximum := (int(data[counter+47])<<8 | int(data[counter+48])) + count - 1
+	var types []string
+	alpn := ""
+	for count < maximum {
+		if count+4 > len(data) {
+			return "|"
+		}
+		typ := hex.EncodeToString(data[count : count+2])
+		length := int(data[count+2])<<8 | int(data[count+3])
+		end := count + 4 + length
+		if end > len(data) {
+			end = len(data)
+		}
+		value := data[count+4 : end]
+		if typ == "0010" && alpn == "" && len(value) > 3 {
+			alpn = string(value[3:])
+		}
+		types = append(types, typ)
+		count += 4 + length
+	}
+	return alpn + "|" + strings.Join(types, "-")
+}
+
+// jarmHash 将 10 个探测的原始结果转换为 62 位 JARM hash
+// 前 30 位为每个探测的加密套件编号和版本，后 32 位为 ALPN 和扩展的 SHA-256 前缀
+func jarmHash(raw string) string {
+	if raw == jarmEmpty {
+		return strings.Repeat("0", 62)
+	}
+
+	var fuzzy, rest strings.Builder
+	for _, handshake := range strings.Split(raw, ",") {
+		parts := strings.SplitN(handshake, "|", 4)
+		for len(parts) < 4 {
+			parts = append(parts, "")
+		}
+		fuzzy.WriteString(jarmCipherByte(parts[0]))
+		fuzzy.WriteString(jarmVersionByte(parts[1]))
+		rest.WriteString(parts[2])
+		rest.WriteString(parts[3])
+	}
+	sum := sha256.Sum256([]byte(rest.String()))
+	return fuzzy.String() + hex.EncodeToString(sum[:])[:32]
+}
+
+// jarmCipherByte 返回加密套件的两位编号，空为 00
+func jarmCipherByte(cipher string) string {
+	if cipher == "" {
+		return "00"
+	}
+	count := 1
+	for _, c := range jarmCipherIndex {
+		if c == cipher {
+			break
+		}
+		count++
+	}
+	return fmt.Sprintf("%02x", count)
+}
+
+// jarmVersionByte 返回 TLS 版本的一位编码：0303 -> d，空为 0
+func jarmVersionByte(version string) string {
+	if len(version) < 4 {
+		return "0"
+	}
+	idx := int(version[3] - '0')
+	if idx < 0 || idx >= len("abcdef") {
+		return "0"
+	}
+	return string("abcdef"[idx])
+}
+
+// jarmGrease 返回随机的 GREASE 值（0x0a0a、0x1a1a ... 0xfafa）
+func jarmGrease() []byte {
+	b := jarmRandom(1)[0]>>4<<4 | 0x0a
+	return []byte{b, b}
+}
+
+// jarmRandom 返回 n 个随机字节
+func jarmRandom(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+// appendUint16 按大端序追加 2 字节整数
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// joinBytes 拼接字节切片
+func joinBytes(list [][]byte) []byte {
+	var out []byte
+	for _, item := range list {
+		out = append(out, item...)
+	}
+	return out
+}
+
+// bytesAt 判断 data 从 offset 开始是否为指定内容
+func bytesAt(data []byte, offset int, want ...byte) bool {
+	if offset < 0 || offset+len(want) > len(data) {
+		return false
+	}
+	for i, b := range want {
+		if data[offset+i] != b {
+			return false
+		}
+	}
+	return true
+}
+
+// jarmRules JARM hash 到指纹名称的映射
+type jarmRules map[string][]string
+
+// newJARMRules 从合并后的 JARM 规则数据（JSON）构建规则表
+// 规则文件为 [{"name": "...", "jarm": "62 位 hash"}]，hash 无效的规则被忽略（rules lint 会报告）
+//
+// 返回：
+//   - jarmRules: hash 到指纹名称的映射
+//   - int: 有效的规则数
+//   - error: 数据结构错误
+func newJARMRules(data []byte) (jarmRules, int, error) {
+	var items []map[string]interface{}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, 0, err
+	}
+	rules := make(jarmRules)
+	count := 0
+	for _, item := range items {
+		name, _ := item["name"].(string)
+		hash, _ := item["jarm"].(string)
+		hash = strings.ToLower(strings.TrimSpace(hash))
+		if name == "" || !isJARMHash(hash) {
+			continue
+		}
+		rules[hash] = appendUnique(rules[hash], name)
+		count++
+	}
+	return rules, count, nil
+}
+
+// match 返回与 JARM hash 对应的指纹名称
+func (r jarmRules) match(hash string) []string {
+	if hash == "" || hash == strings.Repeat("0", 62) {
+		return nil
+	}
+	return r[hash]
+}
+
+// jarm 计算 HTTPS 目标的 JARM hash，非 HTTPS 目标、使用代理或探测失败时返回空字符串
+func (s *Scanner) jarm(ctx context.Context, rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" {
+		return ""
+	}
+	if s.proxies != nil {
+		s.opts.Logger.Debugf("%s 使用代理时不进行 JARM 探测", rawURL)
+		return ""
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+
+	start := time.Now()
+	hash, err := jarmWithDialer(ctx, s.opts.Resolver.DialContext, u.Hostname(), port, s.opts.Timeout)
+	if err != nil {
+		s.opts.Logger.Debugf("%s JARM 探测失败: %v (%s)", rawURL, err, time.Since(start).Round(time.Millisecond))
+		return ""
+	}
+	s.opts.Logger.Debugf("%s JARM: %s (%s)", rawURL, hash, time.Since(start).Round(time.Millisecond))
+	return hash
+}
+
+// isJARMHash 判断是否为 62 位十六进制的 JARM hash
+func isJARMHash(hash string) bool {
+	if len(hash) != 62 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
@@ -0,0 +1,212 @@
+package pkg
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// zeroJARM 所有探测都没有得到 ServerHello 时的 JARM hash
+var zeroJARM = strings.Repeat("0", 62)
+
+// TestJARMLocalTLSServer 对本地 TLS 服务探测两次，hash 应为稳定的非零 62 位 hash
+func TestJARMLocalTLSServer(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// 部分探测故意使用服务端不支持的参数，握手失败的日志没有意义
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	first, err := JARM(context.Background(), u.Hostname(), u.Port(), 2*time.Second)
+	if err != nil {
+		t.Fatalf("JARM: %v", err)
+	}
+	if !isJARMHash(first) || first == zeroJARM {
+		t.Fatalf("JARM = %q, want non-zero 62-char hash", first)
+	}
+
+	second, err := JARM(context.Background(), u.Hostname(), u.Port(), 2*time.Second)
+	if err != nil {
+		t.Fatalf("JARM: %v", err)
+	}
+	if first != second {
+		t.Errorf("JARM not stable: %q != %q", first, second)
+	}
+}
+
+// TestJARMClosedPort 端口关闭时所有探测为空，返回 62 个 0
+func TestJARMClosedPort(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close()
+
+	hash, err := JARM(context.Background(), "127.0.0.1", port, time.Second)
+	if err != nil {
+		t.Fatalf("JARM: %v", err)
+	}
+	if hash != zeroJARM {
+		t.Errorf("JARM = %q, want %q", hash, zeroJARM)
+	}
+}
+
+// TestJARMTimeout 服务端接受连接但不响应时返回超时错误
+func TestJARMTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	if hash, err := JARM(context.Background(), "127.0.0.1", port, 200*time.Millisecond); err == nil {
+		t.Errorf("JARM = %q, want timeout error", hash)
+	}
+}
+
+// TestJARMCancelled ctx 取消后返回错误
+func TestJARMCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := JARM(ctx, "127.0.0.1", "1", time.Second); err == nil {
+		t.Error("JARM with cancelled ctx: want error")
+	}
+}
+
+// serverHello 构造 TLS 1.2 ServerHello 记录，session ID 为 32 字节
+func serverHello(cipher string, extensions ...string) []byte {
+	ext := mustHex(strings.Join(extensions, ""))
+	body := mustHex("0303")
+	body = append(body, make([]byte, 32)...)
+	body = append(body, 32)
+	body = append(body, make([]byte, 32)...)
+	body = append(body, mustHex(cipher)...)
+	body = append(body, 0)
+	body = appendUint16(body, uint16(len(ext)))
+	body = append(body, ext...)
+
+	hs := append([]byte{0x02, 0, byte(len(body) >> 8), byte(len(body))}, body...)
+	return append([]byte{0x16, 0x03, 0x03, byte(len(hs) >> 8), byte(len(hs))}, hs...)
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestJARMParseServerHello(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{
+			name: "alpn and extensions",
+			data: serverHello("c02f",
+				"ff01000100",
+				"0010000b000908687474702f312e31",
+				"000b000403000102",
+			),
+			want: "c02f|0303|http/1.1|ff01-0010-000b",
+		},
+		{
+			name: "no alpn",
+			data: serverHello("1301", "002b00020304", "00330024001d0020"+strings.Repeat("00", 32)),
+			want: "1301|0303||002b-0033",
+		},
+		{name: "tls alert", data: mustHex("15030300020228"), want: "|||"},
+		{name: "not tls", data: []byte("HTTP/1.1 400 Bad Request\r\n\r\n"), want: "|||"},
+		{name: "empty", data: nil, want: "|||"},
+		{name: "truncated", data: serverHello("c02f", "ff01000100")[:60], want: "|||"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jarmParseServerHello(tt.data); got != tt.want {
+				t.Errorf("jarmParseServerHello = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJARMHash(t *testing.T) {
+	if got := jarmHash(jarmEmpty); got != zeroJARM {
+		t.Errorf("jarmHash(empty) = %q, want %q", got, zeroJARM)
+	}
+
+	raw := strings.TrimSuffix(strings.Repeat("c02f|0303|http/1.1|ff01-0010-000b,", 10), ",")
+	got := jarmHash(raw)
+	if !isJARMHash(got) {
+		t.Fatalf("jarmHash = %q, want 62-char hash", got)
+	}
+	// c02f 在 jarmCipherIndex 中的编号和 0303 的版本编码
+	if prefix := jarmCipherByte("c02f") + "d"; !strings.HasPrefix(got, strings.Repeat(prefix, 10)) {
+		t.Errorf("jarmHash = %q, want prefix %q", got, strings.Repeat(prefix, 10))
+	}
+}
+
+func TestJARMRules(t *testing.T) {
+	hash := "29d29d00029d29d00041d41d0000005e2a1d6f0b2e1e4e5e3c0c8a2b9e7f4a"
+	data := []byte(`[
+		{"name": "nginx", "jarm": "` + hash + `"},
+		{"name": "openresty", "jarm": "` + strings.ToUpper(hash) + `"},
+		{"name": "nginx", "jarm": "` + hash + `"},
+		{"name": "bad", "jarm": "1234"},
+		{"name": "", "jarm": "` + hash + `"}
+	]`)
+	rules, count, err := newJARMRules(data)
+	if err != nil {
+		t.Fatalf("newJARMRules: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("count = %d, want 3", count)
+	}
+
+	tests := []struct {
+		hash string
+		want []string
+	}{
+		{hash, []string{"nginx", "openresty"}},
+		{zeroJARM, nil},
+		{"", nil},
+		{strings.Repeat("1", 62), nil},
+	}
+	for _, tt := range tests {
+		if got := rules.match(tt.hash); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("match(%q) = %v, want %v", tt.hash, got, tt.want)
+		}
+	}
+
+	if _, _, err := newJARMRules([]byte(`{"name": "x"}`)); err == nil {
+		t.Error("newJARMRules(object): want error")
+	}
+}
@@ -0,0 +1,682 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件实现指纹规则检查：校验结构、编译正则，并找出空的、重复的和过于宽泛的关键字
+package pkg
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// minKeywordLength 关键字的最小长度（字符数）
+// 过短的关键字（如单个字符的 body 匹配）几乎会命中所有页面
+const minKeywordLength = 3
+
+// 检查问题的级别
+const (
+	LintError   = "error"   // 规则无法加载或无法正确匹配
+	LintWarning = "warning" // 规则可以加载，但很可能存在误报或冗余
+)
+
+// LintIssue 单条检查问题
+type LintIssue struct {
+	Level   string // 问题级别：error / warning
+	Line    int    // 所在行号
+	Column  int    // 所在列号
+	Rule    string // 规则名称
+	Message string // 问题描述
+}
+
+// LintReport 指纹文件的检查报告
+type LintReport struct {
+	File   string      // 文件路径
+	Format string      // 识别出的格式
+	Rules  int         // 规则数量
+	Issues []LintIssue // 发现的问题，按位置排序
+}
+
+// ErrorCount 返回 error 级别的问题数量
+func (r *LintReport) ErrorCount() int {
+	n := 0
+	for _, issue := range r.Issues {
+		if issue.Level == LintError {
+			n++
+		}
+	}
+	return n
+}
+
+// linter 规则检查器，记录检查过程中发现的问题
+type linter struct {
+	report *LintReport
+	seen   map[string]string // 规则内容签名 -> 首次出现的位置，用于查找重复规则
+}
+
+// LintRuleFile 检查指纹规则文件
+// 自动识别格式（EHole、Goby、Wappalyzer、Fingers、FingerPrintHub、ARL、JARM），
+// 校验每条规则的结构，编译所有正则，并标记空的、重复的和过于宽泛的关键字
+//
+// 参数：
+//   - path: 指纹文件路径
+//
+// 返回：
+//   - *LintReport: 检查报告
+//   - error: 文件无法读取、解析或格式无法识别
+func LintRuleFile(path string) (*LintReport, error) {
+	data, err := readRuleFile(path)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseRuleNode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &linter{
+		report: &LintReport{File: path, Format: detectNodeFormat(root)},
+		seen:   make(map[string]string),
+	}
+
+	switch l.report.Format {
+	case FormatEHole:
+		l.lintEHole(root)
+	case FormatGoby:
+		l.lintGoby(root)
+	case FormatWappalyzer:
+		l.lintWappalyzer(root)
+	case FormatFingers:
+		l.lintFingers(root)
+	case FormatFingerPrint:
+		l.lintFingerPrint(root)
+	case FormatARL:
+		l.lintARL(root)
+	case FormatJARM:
+		l.lintJARM(root)
+	default:
+		return nil, fmt.Errorf("无法识别的指纹格式: %s", path)
+	}
+
+	sort.SliceStable(l.report.Issues, func(i, j int) bool {
+		a, b := l.report.Issues[i], l.report.Issues[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return l.report, nil
+}
+
+// add 记录一条问题
+func (l *linter) add(level string, node *yaml.Node, rule, format string, args ...interface{}) {
+	issue := LintIssue{Level: level, Rule: rule, Message: fmt.Sprintf(format, args...)}
+	if node != nil {
+		issue.Line = node.Line
+		issue.Column = node.Column
+	}
+	l.report.Issues = append(l.report.Issues, issue)
+}
+
+// errorf 记录 error 级别的问题
+func (l *linter) errorf(node *yaml.Node, rule, format string, args ...interface{}) {
+	l.add(LintError, node, rule, format, args...)
+}
+
+// warnf 记录 warning 级别的问题
+func (l *linter) warnf(node *yaml.Node, rule, format string, args ...interface{}) {
+	l.add(LintWarning, node, rule, format, args...)
+}
+
+// position 返回节点的 "行:列" 位置
+// 单行 JSON 文件的所有规则都在第 1 行，需要列号才能定位
+func position(node *yaml.Node) string {
+	return fmt.Sprintf("%d:%d", node.Line, node.Column)
+}
+
+// checkDuplicate 检查规则内容是否与之前的规则重复
+// signature 为规则匹配条件的规范化表示
+func (l *linter) checkDuplicate(node *yaml.Node, rule, signature string) {
+	if pos, ok := l.seen[signature]; ok {
+		l.warnf(node, rule, "与 %s 处的规则重复", pos)
+		return
+	}
+	l.seen[signature] = position(node)
+}
+
+// checkKeyword 检查关键字是否为空或过于宽泛
+func (l *linter) checkKeyword(node *yaml.Node, rule, field string) {
+	if strings.TrimSpace(node.Value) == "" {
+		l.errorf(node, rule, "%s 关键字为空", field)
+		return
+	}
+	if n := utf8.RuneCountInString(node.Value); n < minKeywordLength {
+		l.warnf(node, rule, "%s 关键字 %q 过短（%d 个字符），容易误报", field, node.Value, n)
+	}
+}
+
+// checkRegexp 编译正则并检查是否能匹配空字符串（即匹配任意内容）
+func (l *linter) checkRegexp(node *yaml.Node, rule, field, pattern string) {
+	if pattern == "" {
+		l.errorf(node, rule, "%s 正则为空", field)
+		return
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		l.errorf(node, rule, "%s 正则编译失败: %v", field, err)
+		return
+	}
+	if re.MatchString("") {
+		l.warnf(node, rule, "%s 正则 %q 可以匹配空字符串，会命中任意内容", field, pattern)
+	}
+}
+
+// checkHash 检查 favicon hash 格式
+// mmh3 为 32 位有符号整数，无符号形式（超出 int32 但在 uint32 范围内）只给出警告，md5 为 32 位十六进制字符串
+func (l *linter) checkHash(node *yaml.Node, rule, kind string) {
+	value := strings.TrimSpace(node.Value)
+	switch kind {
+	case "mmh3":
+		v, err := strconv.ParseInt(value, 10, 64)
+		switch {
+		case err != nil || v < math.MinInt32 || v > math.MaxUint32:
+			l.errorf(node, rule, "无效的 mmh3 hash: %q", value)
+		case v > math.MaxInt32:
+			l.warnf(node, rule, "mmh3 hash %s 为无符号形式，引擎按有符号形式比较，应写作 %s", value, normalizeMMH3(value))
+		}
+	case "md5":
+		if !md5Regexp.MatchString(value) {
+			l.errorf(node, rule, "无效的 md5 hash: %q", value)
+		}
+	}
+}
+
+// lookaroundRegexp 零宽断言：(?=、(?!、(?<=、(?<!
+var lookaroundRegexp = regexp.MustCompile(`\(\?<?[=!]`)
+
+// md5Regexp md5 hash 格式
+var md5Regexp = regexp.MustCompile(`^[a-fA-F0-9]{32}$`)
+
+// requireSequence 检查节点是否为序列，不是则记录错误
+func (l *linter) requireSequence(node *yaml.Node, rule, field string) bool {
+	if node == nil {
+		l.errorf(nil, rule, "缺少 %s 字段", field)
+		return false
+	}
+	if node.Kind != yaml.SequenceNode {
+		l.errorf(node, rule, "%s 字段应为数组", field)
+		return false
+	}
+	return true
+}
+
+// lintEHole 检查 EHole 格式
+// {"fingerprint": [{"cms", "method", "location", "keyword": []}]}
+func (l *linter) lintEHole(root *yaml.Node) {
+	list := mapValue(root, "fingerprint")
+	if !l.requireSequence(list, "", "fingerprint") {
+		return
+	}
+
+	for _, item := range list.Content {
+		l.report.Rules++
+		if item.Kind != yaml.MappingNode {
+			l.errorf(item, "", "规则应为对象")
+			continue
+		}
+
+		name := mapString(item, "cms")
+		if name == "" {
+			l.errorf(item, "", "缺少 cms 字段")
+		}
+
+		method := mapString(item, "method")
+		location := mapString(item, "location")
+		switch method {
+		case "keyword", "regular", "faviconhash":
+		default:
+			l.errorf(item, name, "不支持的 method: %q", method)
+		}
+		if method != "faviconhash" {
+			switch location {
+			case "body", "header", "title":
+			case "cert":
+				l.errorf(item, name, "不支持的 location: %q，证书条件只支持 ARL 格式（cert=\"...\"）", location)
+			default:
+				l.errorf(item, name, "不支持的 location: %q", location)
+			}
+		}
+
+		keywords := mapValue(item, "keyword")
+		if !l.requireSequence(keywords, name, "keyword") {
+			continue
+		}
+		if len(keywords.Content) == 0 {
+			l.errorf(keywords, name, "keyword 为空")
+			continue
+		}
+
+		var values []string
+		dup := make(map[string]bool)
+		for _, kw := range keywords.Content {
+			if dup[kw.Value] {
+				l.warnf(kw, name, "重复的关键字 %q", kw.Value)
+			}
+			dup[kw.Value] = true
+			values = append(values, kw.Value)
+
+			switch method {
+			case "keyword":
+				l.checkKeyword(kw, name, location)
+			case "regular":
+				// EHole 引擎匹配前会将内容和正则都转为小写
+				l.checkRegexp(kw, name, location, strings.ToLower(kw.Value))
+			case "faviconhash":
+				l.checkHash(kw, name, "mmh3")
+			}
+		}
+
+		sort.Strings(values)
+		l.checkDuplicate(item, name, strings.Join([]string{method, location, strings.Join(values, "\x00")}, "\x01"))
+	}
+}
+
+// gobyLogicRegexp Goby logic 表达式允许的字符：标签、&&、||、!、括号和空白
+var gobyLogicRegexp = regexp.MustCompile(`^[\w\s()!&|]+$`)
+
+// gobyLabelRegexp Goby logic 表达式中的标签
+var gobyLabelRegexp = regexp.MustCompile(`\w+`)
+
+// lintGoby 检查 Goby 格式
+// [{"name", "logic", "rule": [{"label", "feature", "is_equal"}]}]
+func (l *linter) lintGoby(root *yaml.Node) {
+	for _, item := range root.Content {
+		l.report.Rules++
+		if item.Kind != yaml.MappingNode {
+			l.errorf(item, "", "规则应为对象")
+			continue
+		}
+
+		name := mapString(item, "name")
+		if name == "" {
+			l.errorf(item, "", "缺少 name 字段")
+		}
+
+		rules := mapValue(item, "rule")
+		if !l.requireSequence(rules, name, "rule") {
+			continue
+		}
+
+		// 收集标签
+		labels := make(map[string]bool)
+		var features []string
+		for _, r := range rules.Content {
+			label := mapString(r, "label")
+			feature := mapValue(r, "feature")
+			if label == "" {
+				l.errorf(r, name, "缺少 label 字段")
+			} else if labels[label] {
+				l.errorf(r, name, "重复的 label: %q", label)
+			}
+			labels[label] = true
+
+			if feature == nil {
+				l.errorf(r, name, "缺少 feature 字段")
+				continue
+			}
+			isEqual := mapString(r, "is_equal")
+			if isEqual != "false" {
+				// is_equal 为 false 时表示"不包含"，短关键字不会造成误报
+				l.checkKeyword(feature, name, "feature")
+			}
+			features = append(features, isEqual+":"+feature.Value)
+		}
+
+		// 检查 logic 表达式
+		logicNode := mapValue(item, "logic")
+		logic := mapString(item, "logic")
+		if logic == "" {
+			l.errorf(item, name, "缺少 logic 字段")
+			continue
+		}
+		if !gobyLogicRegexp.MatchString(logic) || !balancedParens(logic) {
+			l.errorf(logicNode, name, "logic 表达式语法错误: %q", logic)
+		}
+		used := make(map[string]bool)
+		for _, label := range gobyLabelRegexp.FindAllString(logic, -1) {
+			used[label] = true
+			if !labels[label] {
+				l.errorf(logicNode, name, "logic 引用了不存在的 label: %q", label)
+			}
+		}
+		for label := range labels {
+			if label != "" && !used[label] {
+				l.warnf(logicNode, name, "label %q 未在 logic 中使用", label)
+			}
+		}
+
+		sort.Strings(features)
+		l.checkDuplicate(item, name, logic+"\x01"+strings.Join(features, "\x00"))
+	}
+}
+
+// balancedParens 检查括号是否配对
+func balancedParens(s string) bool {
+	depth := 0
+	for _, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// lintWappalyzer 检查 Wappalyzer 格式
+// {"apps": {"name": {"html": [], "headers": {}, ...}}}
+// 模式格式为 "正则\;version:\1\;confidence:50"，只有第一段是正则
+func (l *linter) lintWappalyzer(root *yaml.Node) {
+	apps := mapValue(root, "apps")
+	if apps == nil || apps.Kind != yaml.MappingNode {
+		l.errorf(apps, "", "apps 字段应为对象")
+		return
+	}
+
+	names := make(map[string]string)
+	for i := 0; i+1 < len(apps.Content); i += 2 {
+		keyNode, app := apps.Content[i], apps.Content[i+1]
+		name := keyNode.Value
+		l.report.Rules++
+
+		if pos, ok := names[name]; ok {
+			l.errorf(keyNode, name, "重复的应用名称，已在 %s 处定义", pos)
+		}
+		names[name] = position(keyNode)
+
+		if app.Kind != yaml.MappingNode {
+			l.errorf(app, name, "应用定义应为对象")
+			continue
+		}
+
+		// 字符串或字符串数组形式的模式
+		for _, field := range []string{"html", "scripts", "scriptSrc", "js", "css"} {
+			for _, n := range scalarNodes(mapValue(app, field)) {
+				l.checkWappalyzerPattern(n, name, field, true)
+			}
+		}
+
+		// 对象形式的模式，值为空表示只检查存在性
+		for _, field := range []string{"headers", "cookies", "meta"} {
+			m := mapValue(app, field)
+			if m == nil || m.Kind != yaml.MappingNode {
+				continue
+			}
+			for j := 0; j+1 < len(m.Content); j += 2 {
+				for _, n := range scalarNodes(m.Content[j+1]) {
+					l.checkWappalyzerPattern(n, name, field+"."+m.Content[j].Value, false)
+				}
+			}
+		}
+	}
+}
+
+// checkWappalyzerPattern 检查单个 Wappalyzer 模式
+// requirePattern 为 true 时，空模式或匹配空字符串的模式视为过于宽泛
+func (l *linter) checkWappalyzerPattern(node *yaml.Node, rule, field string, requirePattern bool) {
+	pattern := strings.ToLower(strings.Split(node.Value, "\\;")[0])
+	if pattern == "" {
+		if requirePattern {
+			l.warnf(node, rule, "%s 模式为空，会命中任意内容", field)
+		}
+		return
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		// Wappalyzer 官方规则使用 JavaScript 正则，其中的零宽断言 Go 不支持，引擎跳过这些模式，其余模式仍然有效
+		if lookaroundRegexp.MatchString(pattern) {
+			l.warnf(node, rule, "%s 正则使用了 Go 不支持的零宽断言，该模式被引擎跳过: %q", field, pattern)
+			return
+		}
+		l.errorf(node, rule, "%s 正则编译失败: %v", field, err)
+		return
+	}
+	if requirePattern && re.MatchString("") {
+		l.warnf(node, rule, "%s 正则 %q 可以匹配空字符串，会命中任意内容", field, pattern)
+	}
+}
+
+// lintFingers 检查 chainreactors fingers 格式
+// [{"name", "rule": [{"regexps": {...}, "favicon": {...}, "send_data"}]}]
+func (l *linter) lintFingers(root *yaml.Node) {
+	names := make(map[string]string)
+	for _, item := range root.Content {
+		l.report.Rules++
+		if item.Kind != yaml.MappingNode {
+			l.errorf(item, "", "规则应为对象")
+			continue
+		}
+
+		name := mapString(item, "name")
+		if name == "" {
+			l.errorf(item, "", "缺少 name 字段")
+		} else if pos, ok := names[name]; ok {
+			l.warnf(item, name, "重复的指纹名称，已在 %s 处定义", pos)
+		} else {
+			names[name] = position(item)
+		}
+
+		rules := mapValue(item, "rule")
+		if !l.requireSequence(rules, name, "rule") {
+			continue
+		}
+		if len(rules.Content) == 0 {
+			l.errorf(rules, name, "rule 为空")
+		}
+
+		for _, r := range rules.Content {
+			regexps := mapValue(r, "regexps")
+			favicon := mapValue(r, "favicon")
+			if regexps == nil && favicon == nil && mapString(r, "send_data") == "" {
+				l.errorf(r, name, "规则没有任何匹配条件")
+				continue
+			}
+
+			// 关键字匹配
+			for _, field := range []string{"body", "header", "cert"} {
+				for _, n := range scalarNodes(mapValue(regexps, field)) {
+					l.checkKeyword(n, name, field)
+				}
+			}
+			// 正则匹配，引擎编译时统一加上 (?i)
+			for _, field := range []string{"regexp", "vuln"} {
+				for _, n := range scalarNodes(mapValue(regexps, field)) {
+					l.checkRegexp(n, name, field, "(?i)"+n.Value)
+				}
+			}
+			for _, n := range scalarNodes(mapValue(regexps, "version")) {
+				if _, err := regexp.Compile(n.Value); err != nil {
+					l.errorf(n, name, "version 正则编译失败: %v", err)
+				}
+			}
+			// hash 匹配
+			for _, n := range scalarNodes(mapValue(regexps, "md5")) {
+				l.checkHash(n, name, "md5")
+			}
+			for _, n := range scalarNodes(mapValue(regexps, "mmh3")) {
+				l.checkHash(n, name, "mmh3")
+			}
+			for _, n := range scalarNodes(mapValue(favicon, "md5")) {
+				l.checkHash(n, name, "md5")
+			}
+			for _, n := range scalarNodes(mapValue(favicon, "mmh3")) {
+				l.checkHash(n, name, "mmh3")
+			}
+		}
+	}
+}
+
+// lintFingerPrint 检查 FingerPrintHub v4 格式（nuclei 模板的 JSON 形式）
+// [{"id", "info": {"name"}, "http": [{"path", "matchers": [{"type", "words" / "regex" / "hash"}]}]}]
+func (l *linter) lintFingerPrint(root *yaml.Node) {
+	ids := make(map[string]string)
+	for _, item := range root.Content {
+		l.report.Rules++
+		if item.Kind != yaml.MappingNode {
+			l.errorf(item, "", "规则应为对象")
+			continue
+		}
+
+		id := mapString(item, "id")
+		name := mapString(mapValue(item, "info"), "name")
+		if name == "" {
+			name = id
+		}
+		if id == "" {
+			l.errorf(item, name, "缺少 id 字段")
+		} else if pos, ok := ids[id]; ok {
+			// 引擎会加载每一个模板，重复的 id 只会让结果中出现同名指纹
+			l.warnf(item, name, "重复的模板 id，已在 %s 处定义", pos)
+		} else {
+			ids[id] = position(item)
+		}
+		if mapString(mapValue(item, "info"), "name") == "" {
+			l.warnf(item, name, "缺少 info.name 字段")
+		}
+
+		requests := mapValue(item, "http")
+		if !l.requireSequence(requests, name, "http") {
+			continue
+		}
+
+		for _, req := range requests.Content {
+			matchers := mapValue(req, "matchers")
+			if !l.requireSequence(matchers, name, "matchers") {
+				continue
+			}
+			if len(matchers.Content) == 0 {
+				l.errorf(matchers, name, "matchers 为空")
+			}
+			for _, m := range matchers.Content {
+				switch typ := mapString(m, "type"); typ {
+				case "word":
+					words := scalarNodes(mapValue(m, "words"))
+					if len(words) == 0 {
+						l.errorf(m, name, "word matcher 缺少 words")
+					}
+					for _, w := range words {
+						l.checkKeyword(w, name, "words")
+					}
+				case "regex":
+					regexes := scalarNodes(mapValue(m, "regex"))
+					if len(regexes) == 0 {
+						l.errorf(m, name, "regex matcher 缺少 regex")
+					}
+					for _, r := range regexes {
+						l.checkRegexp(r, name, "regex", r.Value)
+					}
+				case "favicon":
+					hashes := scalarNodes(mapValue(m, "hash"))
+					if len(hashes) == 0 {
+						l.errorf(m, name, "favicon matcher 缺少 hash")
+					}
+					for _, h := range hashes {
+						if md5Regexp.MatchString(h.Value) {
+							continue
+						}
+						l.checkHash(h, name, "mmh3")
+					}
+				case "status", "dsl", "binary", "size":
+				default:
+					l.errorf(m, name, "不支持的 matcher 类型: %q", typ)
+				}
+			}
+		}
+	}
+}
+
+// lintARL 检查 ARL YAML 格式
+// [{"name", "rule": "body=\"xxx\" && header=\"yyy\""}]
+func (l *linter) lintARL(root *yaml.Node) {
+	for _, item := range root.Content {
+		l.report.Rules++
+		if item.Kind != yaml.MappingNode {
+			l.errorf(item, "", "规则应为对象")
+			continue
+		}
+
+		name := mapString(item, "name")
+		if name == "" {
+			l.errorf(item, "", "缺少 name 字段")
+		}
+
+		ruleNode := mapValue(item, "rule")
+		rule := mapString(item, "rule")
+		if rule == "" {
+			l.errorf(item, name, "rule 为空")
+			continue
+		}
+
+		conditions, err := parseARLRuleStrict(rule)
+		if err != nil {
+			l.errorf(ruleNode, name, "%v", err)
+			continue
+		}
+
+		var parts []string
+		for _, cond := range conditions {
+			parts = append(parts, cond.Type+"="+strings.ToLower(cond.Keyword))
+			switch {
+			case cond.Keyword == "":
+				// 匹配时忽略空关键字，规则仍然有效
+				l.warnf(ruleNode, name, "%s 关键字为空，匹配时被忽略", cond.Type)
+			case cond.Type == "icon_hash":
+				l.checkHash(&yaml.Node{Value: cond.Keyword, Line: ruleNode.Line, Column: ruleNode.Column}, name, "mmh3")
+			default:
+				l.checkKeyword(&yaml.Node{Value: cond.Keyword, Line: ruleNode.Line, Column: ruleNode.Column}, name, cond.Type)
+			}
+		}
+
+		sort.Strings(parts)
+		l.checkDuplicate(item, name, strings.Join(parts, "\x00"))
+	}
+}
+
+// lintJARM 检查 JARM 格式
+// [{"name", "jarm": "62 位十六进制 hash"}]
+func (l *linter) lintJARM(root *yaml.Node) {
+	for _, item := range root.Content {
+		l.report.Rules++
+		if item.Kind != yaml.MappingNode {
+			l.errorf(item, "", "规则应为对象")
+			continue
+		}
+
+		name := mapString(item, "name")
+		if name == "" {
+			l.errorf(item, "", "缺少 name 字段")
+		}
+
+		hashNode := mapValue(item, "jarm")
+		hash := strings.ToLower(mapString(item, "jarm"))
+		switch {
+		case hash == "":
+			l.errorf(item, name, "jarm 为空")
+			continue
+		case !isJARMHash(hash):
+			l.errorf(hashNode, name, "无效的 JARM hash %q，应为 62 位十六进制", hash)
+			continue
+		case hash == strings.Repeat("0", 62):
+			l.warnf(hashNode, name, "全 0 的 JARM hash 表示目标不支持 TLS，不会命中")
+		}
+		l.checkDuplicate(item, name, hash)
+	}
+}
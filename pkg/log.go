@@ -0,0 +1,114 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件实现分级日志，日志与扫描结果分开输出：结果写入标准输出，日志写入标准错误或日志文件
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogLevel 日志级别，数值越大输出越详细
+type LogLevel int
+
+// 日志级别
+const (
+	LevelError LogLevel = iota // 错误
+	LevelWarn                  // 警告
+	LevelInfo                  // 一般信息，如指纹加载情况
+	LevelDebug                 // 调试信息：每个请求、响应状态、命中的规则和耗时
+	LevelTrace                 // 更详细的调试信息：favicon 等附属资源的获取
+)
+
+// levelPrefixes 各级别日志的前缀
+var levelPrefixes = map[LogLevel]string{
+	LevelError: "[!]",
+	LevelWarn:  "[!]",
+	LevelInfo:  "[*]",
+	LevelDebug: "[debug]",
+	LevelTrace: "[trace]",
+}
+
+// Logger 分级日志
+// 可以被多个 goroutine 同时使用；nil Logger 不输出任何内容
+type Logger struct {
+	mu    sync.Mutex
+	level LogLevel  // 输出的最高级别
+	out   io.Writer // 日志输出位置，通常为标准错误
+	file  io.Writer // 日志文件，为 nil 时不写入；每行带有时间戳
+}
+
+// NewLogger 创建日志
+//
+// 参数：
+//   - out: 日志输出位置，为 nil 时只写入日志文件
+//   - level: 输出的最高级别
+//
+// 返回：
+//   - *Logger: 日志实例
+func NewLogger(out io.Writer, level LogLevel) *Logger {
+	return &Logger{out: out, level: level}
+}
+
+// SetOutput 设置日志输出位置
+func (l *Logger) SetOutput(out io.Writer) {
+	l.mu.Lock()
+	l.out = out
+	l.mu.Unlock()
+}
+
+// SetFile 设置日志文件，日志同时写入文件
+func (l *Logger) SetFile(file io.Writer) {
+	l.mu.Lock()
+	l.file = file
+	l.mu.Unlock()
+}
+
+// Enabled 判断指定级别的日志是否会被输出
+func (l *Logger) Enabled(level LogLevel) bool {
+	return l != nil && level <= l.level
+}
+
+// Errorf 输出错误日志
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.logf(LevelError, format, args...)
+}
+
+// Warnf 输出警告日志
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.logf(LevelWarn, format, args...)
+}
+
+// Infof 输出一般信息
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.logf(LevelInfo, format, args...)
+}
+
+// Debugf 输出调试信息
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.logf(LevelDebug, format, args...)
+}
+
+// Tracef 输出详细的调试信息
+func (l *Logger) Tracef(format string, args ...interface{}) {
+	l.logf(LevelTrace, format, args...)
+}
+
+// logf 按级别输出一行日志
+func (l *Logger) logf(level LogLevel, format string, args ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	line := levelPrefixes[level] + " " + strings.TrimRight(fmt.Sprintf(format, args...), "\n") + "\n"
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.out != nil {
+		io.WriteString(l.out, line)
+	}
+	if l.file != nil {
+		io.WriteString(l.file, time.Now().Format("2006-01-02 15:04:05.000 ")+line)
+	}
+}
@@ -0,0 +1,445 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件负责从本地文件导入已保存的 HTTP 响应，用于离线指纹识别
+// 支持原始 HTTP 响应文件、HAR 文件、WARC 文件、Burp XML 以及包含这些文件的目录
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LoadResponses 从文件或目录加载已保存的 HTTP 响应
+// 目录会被递归遍历，无法解析的文件直接跳过；单个文件解析失败则返回错误
+//
+// 参数：
+//   - path: 文件或目录路径
+//
+// 返回：
+//   - []*Response: 解析后的响应列表
+//   - error: 读取或解析错误
+func LoadResponses(path string) ([]*Response, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return LoadResponseFile(path)
+	}
+
+	var responses []*Response
+	err = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		if rs, err := LoadResponseFile(p); err == nil {
+			responses = append(responses, rs...)
+		}
+		return nil
+	})
+	return responses, err
+}
+
+// LoadResponseFile 从单个文件加载 HTTP 响应
+// 根据扩展名选择解析方式：.har 为 HAR，.warc / .warc.gz 为 WARC，
+// .xml 为 Burp "Save items" 导出文件，其余按原始 HTTP 响应解析
+// 原始响应文件不包含 URL，使用文件路径作为结果中的 URL
+//
+// 参数：
+//   - path: 文件路径
+//
+// 返回：
+//   - []*Response: 解析后的响应列表
+//   - error: 读取或解析错误
+func LoadResponseFile(path string) ([]*Response, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.ToLower(filepath.Base(path))
+	switch {
+	case strings.HasSuffix(name, ".har"):
+		return parseHAR(data)
+	case strings.HasSuffix(name, ".warc"), strings.HasSuffix(name, ".warc.gz"):
+		return parseWARC(data)
+	case strings.HasSuffix(name, ".xml"):
+		return parseBurpXML(data)
+	}
+
+	resp, err := parseRawResponse(data, path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return []*Response{resp}, nil
+}
+
+// parseRawResponse 解析原始 HTTP 响应报文
+// 兼容 LF 换行、HTTP/2 状态行、chunked 传输以及 gzip/deflate 压缩的响应体
+// 非 chunked 响应忽略 Content-Length，以分隔空行后的全部内容作为响应体，
+// 避免手工编辑过的响应文件被截断
+//
+// 参数：
+//   - data: 原始响应内容
+//   - targetURL: 响应对应的 URL
+//
+// 返回：
+//   - *Response: 解析后的响应
+//   - error: 解析错误
+func parseRawResponse(data []byte, targetURL string) (*Response, error) {
+	data = bytes.TrimLeft(data, "\r\n\t ")
+	if !bytes.HasPrefix(data, []byte("HTTP/")) {
+		return nil, fmt.Errorf("not an HTTP response")
+	}
+	data = normalizeStatusLine(data)
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body []byte
+	if len(resp.TransferEncoding) > 0 {
+		body, _ = io.ReadAll(resp.Body)
+	} else {
+		body = splitRawBody(data)
+	}
+	body = decodeContentEncoding(resp.Header, body)
+
+	return parseResponse([]string{targetURL, "0"}, resp, body), nil
+}
+
+// normalizeStatusLine 将 "HTTP/2 200" 形式的状态行补全为 "HTTP/2.0 200"
+// net/http 只接受 HTTP/x.y 格式的版本号
+func normalizeStatusLine(data []byte) []byte {
+	end := bytes.IndexByte(data, ' ')
+	if end < 0 || bytes.IndexByte(data[:end], '.') >= 0 {
+		return data
+	}
+	fixed := make([]byte, 0, len(data)+2)
+	fixed = append(fixed, data[:end]...)
+	fixed = append(fixed, ".0"...)
+	return append(fixed, data[end:]...)
+}
+
+// splitRawBody 返回原始报文中头部之后的内容
+// 同时兼容 CRLF 和 LF 两种换行
+func splitRawBody(data []byte) []byte {
+	crlf := bytes.Index(data, []byte("\r\n\r\n"))
+	lf := bytes.Index(data, []byte("\n\n"))
+	switch {
+	case crlf >= 0 && (lf < 0 || crlf < lf):
+		return data[crlf+4:]
+	case lf >= 0:
+		return data[lf+2:]
+	}
+	return nil
+}
+
+// decodeContentEncoding 解压 gzip/deflate 编码的响应体
+// 与 net/http 自动解压的行为保持一致：解压成功后移除 Content-Encoding 和 Content-Length
+func decodeContentEncoding(header http.Header, body []byte) []byte {
+	var r io.Reader
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Encoding"))) {
+	case "gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return body
+		}
+		defer gr.Close()
+		r = gr
+	case "deflate":
+		fr := flate.NewReader(bytes.NewReader(body))
+		defer fr.Close()
+		r = fr
+	default:
+		return body
+	}
+
+	decoded, err := io.ReadAll(r)
+	if err != nil && len(decoded) == 0 {
+		return body
+	}
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+	return decoded
+}
+
+// harFile HAR 文件结构（只包含指纹识别需要的字段）
+type harFile struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+// harEntry HAR 中的单个请求/响应记录
+type harEntry struct {
+	Request struct {
+		URL string `json:"url"`
+	} `json:"request"`
+	Response struct {
+		Status      int    `json:"status"`
+		HTTPVersion string `json:"httpVersion"`
+		Headers     []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"headers"`
+		Content struct {
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+// parseHAR 解析 HAR 文件
+// 跳过没有响应的记录（status 为 0），HTTP/2 伪头部（以 ":" 开头）被忽略
+// HAR 中的 content.text 已经解压，因此移除 Content-Encoding 头
+//
+// 参数：
+//   - data: HAR 文件内容
+//
+// 返回：
+//   - []*Response: 解析后的响应列表
+//   - error: 解析错误
+func parseHAR(data []byte) ([]*Response, error) {
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("invalid HAR: %v", err)
+	}
+
+	var responses []*Response
+	for _, entry := range har.Log.Entries {
+		if entry.Response.Status == 0 || entry.Request.URL == "" {
+			continue
+		}
+
+		resp := &http.Response{
+			StatusCode: entry.Response.Status,
+			Header:     make(http.Header),
+		}
+		resp.ProtoMajor, resp.ProtoMinor = parseProtoVersion(entry.Response.HTTPVersion)
+		resp.Proto = fmt.Sprintf("HTTP/%d.%d", resp.ProtoMajor, resp.ProtoMinor)
+		for _, h := range entry.Response.Headers {
+			if strings.HasPrefix(h.Name, ":") {
+				continue
+			}
+			resp.Header.Add(h.Name, h.Value)
+		}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+
+		body := []byte(entry.Response.Content.Text)
+		if entry.Response.Content.Encoding == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Response.Content.Text)
+			if err == nil {
+				body = decoded
+			}
+		}
+
+		responses = append(responses, parseResponse([]string{entry.Request.URL, "0"}, resp, body))
+	}
+	return responses, nil
+}
+
+// parseProtoVersion 解析 "HTTP/1.1"、"h2"、"http/2.0" 等形式的协议版本
+// 无法识别时按 HTTP/1.1 处理
+func parseProtoVersion(version string) (int, int) {
+	v := strings.ToUpper(strings.TrimSpace(version))
+	switch v {
+	case "H2", "HTTP/2", "HTTP/2.0":
+		return 2, 0
+	case "H3", "HTTP/3", "HTTP/3.0":
+		return 3, 0
+	}
+	if major, minor, ok := http.ParseHTTPVersion(v); ok {
+		return major, minor
+	}
+	return 1, 1
+}
+
+// parseWARC 解析 WARC 文件
+// 只处理 WARC-Type 为 response 且内容为 application/http 的记录，
+// 支持整体 gzip 或逐条记录 gzip 压缩的 .warc.gz
+//
+// 参数：
+//   - data: WARC 文件内容
+//
+// 返回：
+//   - []*Response: 解析后的响应列表
+//   - error: 解析错误
+func parseWARC(data []byte) ([]*Response, error) {
+	var r io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	br := bufio.NewReader(r)
+	tp := textproto.NewReader(br)
+
+	var responses []*Response
+	for {
+		// 读取版本行，跳过记录之间的空行
+		line, err := tp.ReadLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return responses, err
+		}
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "WARC/") {
+			return responses, fmt.Errorf("invalid WARC record: %q", line)
+		}
+
+		header, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return responses, err
+		}
+
+		length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+		if err != nil {
+			return responses, fmt.Errorf("invalid WARC Content-Length: %v", err)
+		}
+		block := make([]byte, length)
+		if _, err := io.ReadFull(br, block); err != nil {
+			return responses, err
+		}
+
+		if !strings.EqualFold(header.Get("WARC-Type"), "response") ||
+			!strings.Contains(strings.ToLower(header.Get("Content-Type")), "application/http") {
+			continue
+		}
+
+		targetURL := strings.Trim(header.Get("WARC-Target-URI"), "<>")
+		if resp, err := parseRawResponse(block, targetURL); err == nil {
+			responses = append(responses, resp)
+		}
+	}
+	return responses, nil
+}
+
+// burpItems Burp "Save items" 导出的 XML 结构
+type burpItems struct {
+	Items []struct {
+		URL      string `xml:"url"`
+		Response struct {
+			Base64 bool   `xml:"base64,attr"`
+			Data   string `xml:",chardata"`
+		} `xml:"response"`
+	} `xml:"item"`
+}
+
+// parseBurpXML 解析 Burp "Save items" 导出的 XML 文件
+// 响应内容为完整的原始 HTTP 报文，通常经过 base64 编码
+//
+// 参数：
+//   - data: XML 文件内容
+//
+// 返回：
+//   - []*Response: 解析后的响应列表
+//   - error: 解析错误
+func parseBurpXML(data []byte) ([]*Response, error) {
+	var items burpItems
+	if err := xml.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("invalid Burp XML: %v", err)
+	}
+
+	var responses []*Response
+	for _, item := range items.Items {
+		raw := []byte(item.Response.Data)
+		if item.Response.Base64 {
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(item.Response.Data))
+			if err != nil {
+				continue
+			}
+			raw = decoded
+		}
+		if resp, err := parseRawResponse(raw, strings.TrimSpace(item.URL)); err == nil {
+			responses = append(responses, resp)
+		}
+	}
+	return responses, nil
+}
+
+// responseOrigin 返回 URL 的源（scheme://host[:port]）
+// 无法解析时原样返回
+func responseOrigin(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// isRootPath 判断 URL 是否指向站点首页
+func isRootPath(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return u.Path == "" || u.Path == "/"
+}
+
+// groupByOrigin 将响应按源分组，保持源首次出现的顺序
+func groupByOrigin(responses []*Response) [][]*Response {
+	var groups [][]*Response
+	index := make(map[string]int)
+	for _, resp := range responses {
+		origin := responseOrigin(resp.URL)
+		i, ok := index[origin]
+		if !ok {
+			i = len(groups)
+			index[origin] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], resp)
+	}
+	return groups
+}
+
+// offlineGetter 返回从已导入响应中查找资源的 resourceGetter
+// 用于离线匹配时获取 favicon 和 manifest，找不到时返回错误而不是发送请求
+//
+// 参数：
+//   - responses: 已导入的响应列表
+func offlineGetter(responses []*Response) resourceGetter {
+	index := make(map[string]*Response, len(responses))
+	for _, resp := range responses {
+		index[resp.URL] = resp
+	}
+	return func(ctx context.Context, rawURL, pageURL string) ([]byte, string, error) {
+		resp, ok := index[rawURL]
+		if !ok {
+			return nil, "", fmt.Errorf("offline resource not found: %s", rawURL)
+		}
+		if resp.StatusCode != 200 {
+			return nil, "", fmt.Errorf("request failed: %d", resp.StatusCode)
+		}
+		return resp.RawBody(), http.Header(resp.HeaderMap).Get("Content-Type"), nil
+	}
+}
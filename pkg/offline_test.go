@@ -0,0 +1,218 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// gzipBytes 返回 gzip 压缩后的内容
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// readFixture 读取 testdata/offline 中的测试文件
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "offline", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseRawResponse(t *testing.T) {
+	gzipped := append([]byte("HTTP/1.1 200 OK\r\nContent-Encoding: gzip\r\nContent-Type: text/html\r\n\r\n"),
+		gzipBytes(t, []byte("<title>Gzip</title>"))...)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		status int
+		title  string
+		body   string
+		server string
+		proto  string
+	}{
+		{
+			name:   "crlf",
+			data:   []byte("HTTP/1.1 200 OK\r\nServer: nginx\r\nContent-Type: text/html\r\n\r\n<title>CRLF</title>"),
+			status: 200, title: "CRLF", body: "<title>CRLF</title>", server: "nginx", proto: "HTTP/1.1",
+		},
+		{
+			name:   "lf only with leading blank lines",
+			data:   []byte("\n\nHTTP/1.1 403 Forbidden\nX-Powered-By: PHP/8.1\n\n<title>LF</title>"),
+			status: 403, title: "LF", body: "<title>LF</title>", server: "PHP/8.1", proto: "HTTP/1.1",
+		},
+		{
+			name:   "http/2 status line",
+			data:   []byte("HTTP/2 200\r\ncontent-type: text/html\r\n\r\n<title>H2</title>"),
+			status: 200, title: "H2", body: "<title>H2</title>", proto: "HTTP/2.0",
+		},
+		{
+			name:   "chunked",
+			data:   []byte("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n7\r\n<title>\r\n8\r\nChunked<\r\n7\r\n/title>\r\n0\r\n\r\n"),
+			status: 200, title: "Chunked", body: "<title>Chunked</title>", proto: "HTTP/1.1",
+		},
+		{
+			name:   "gzip",
+			data:   gzipped,
+			status: 200, title: "Gzip", body: "<title>Gzip</title>", proto: "HTTP/1.1",
+		},
+		{
+			name:   "content-length ignored",
+			data:   []byte("HTTP/1.1 200 OK\r\nContent-Length: 3\r\n\r\n<title>Edited</title>"),
+			status: 200, title: "Edited", body: "<title>Edited</title>", proto: "HTTP/1.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := parseRawResponse(tt.data, "https://example.com/")
+			if err != nil {
+				t.Fatalf("parseRawResponse: %v", err)
+			}
+			if resp.URL != "https://example.com/" || resp.StatusCode != tt.status || resp.Title != tt.title ||
+				resp.Body != tt.body || resp.Server != tt.server || resp.Proto != tt.proto {
+				t.Errorf("got url=%q status=%d title=%q body=%q server=%q proto=%q",
+					resp.URL, resp.StatusCode, resp.Title, resp.Body, resp.Server, resp.Proto)
+			}
+			if _, ok := resp.HeaderMap["Content-Encoding"]; ok {
+				t.Errorf("Content-Encoding not removed: %v", resp.HeaderMap)
+			}
+		})
+	}
+
+	for _, data := range []string{"", "GET / HTTP/1.1\r\n\r\n", "<html></html>"} {
+		if _, err := parseRawResponse([]byte(data), "x"); err == nil {
+			t.Errorf("parseRawResponse(%q): want error", data)
+		}
+	}
+}
+
+func TestParseHAR(t *testing.T) {
+	responses, err := parseHAR(readFixture(t, "sample.har"))
+	if err != nil {
+		t.Fatalf("parseHAR: %v", err)
+	}
+	if len(responses) != 2 {
+		t.Fatalf("got %d responses, want 2 (entry without response skipped)", len(responses))
+	}
+
+	page := responses[0]
+	if page.URL != "https://example.com/" || page.StatusCode != 200 || page.Title != "Example" || page.Server != "nginx" || page.Proto != "HTTP/2.0" {
+		t.Errorf("page: url=%q status=%d title=%q server=%q proto=%q", page.URL, page.StatusCode, page.Title, page.Server, page.Proto)
+	}
+	for name := range page.HeaderMap {
+		if strings.HasPrefix(name, ":") || name == "Content-Encoding" {
+			t.Errorf("header %q not removed", name)
+		}
+	}
+
+	icon := responses[1]
+	if icon.URL != "https://example.com/favicon.ico" || !bytes.Equal(icon.RawBody(), []byte("\x00\x00\x01\x00icon")) {
+		t.Errorf("icon: url=%q body=%q", icon.URL, icon.RawBody())
+	}
+
+	if _, err := parseHAR([]byte("not json")); err == nil {
+		t.Error("parseHAR(invalid): want error")
+	}
+}
+
+func TestParseWARC(t *testing.T) {
+	plain := readFixture(t, "sample.warc")
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"plain", plain},
+		{"gzip", gzipBytes(t, plain)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses, err := parseWARC(tt.data)
+			if err != nil {
+				t.Fatalf("parseWARC: %v", err)
+			}
+			var got []string
+			for _, resp := range responses {
+				got = append(got, resp.URL+" "+resp.Title)
+			}
+			// warcinfo 和 request 记录被跳过，WARC-Target-URI 的尖括号被去掉
+			want := []string{"http://example.org/ WARC Page", "http://example.org/missing "}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			if responses[0].Server != "Apache" || responses[1].StatusCode != 404 {
+				t.Errorf("server=%q status=%d", responses[0].Server, responses[1].StatusCode)
+			}
+		})
+	}
+
+	if _, err := parseWARC([]byte("HTTP/1.1 200 OK\r\n\r\n")); err == nil {
+		t.Error("parseWARC(not warc): want error")
+	}
+	if _, err := parseWARC([]byte("WARC/1.0\r\nWARC-Type: response\r\nContent-Length: 100\r\n\r\nshort")); err == nil {
+		t.Error("parseWARC(truncated): want error")
+	}
+}
+
+func TestParseBurpXML(t *testing.T) {
+	responses, err := parseBurpXML(readFixture(t, "burp.xml"))
+	if err != nil {
+		t.Fatalf("parseBurpXML: %v", err)
+	}
+	var got []string
+	for _, resp := range responses {
+		got = append(got, resp.URL+" "+resp.Title)
+	}
+	// base64 无效的条目被跳过
+	want := []string{"https://burp.example/ Burp Base64", "https://burp.example/plain Burp Plain"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if responses[0].Server != "Tomcat" {
+		t.Errorf("server = %q, want Tomcat", responses[0].Server)
+	}
+
+	if _, err := parseBurpXML([]byte("<items><item>")); err == nil {
+		t.Error("parseBurpXML(invalid): want error")
+	}
+}
+
+func TestLoadResponses(t *testing.T) {
+	dir := filepath.Join("testdata", "offline")
+	responses, err := LoadResponses(dir)
+	if err != nil {
+		t.Fatalf("LoadResponses: %v", err)
+	}
+	// burp.xml 2 条、raw.http 1 条、sample.har 2 条、sample.warc 2 条
+	if len(responses) != 7 {
+		t.Errorf("got %d responses, want 7", len(responses))
+	}
+
+	raw, err := LoadResponseFile(filepath.Join(dir, "raw.http"))
+	if err != nil {
+		t.Fatalf("LoadResponseFile: %v", err)
+	}
+	if len(raw) != 1 || raw[0].URL != filepath.Join(dir, "raw.http") || raw[0].Title != "Raw LF" {
+		t.Errorf("raw: %+v", raw)
+	}
+
+	groups := groupByOrigin(responses)
+	if len(groups) != 4 {
+		t.Errorf("got %d origins, want 4", len(groups))
+	}
+}
@@ -5,23 +5,139 @@ package pkg
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/gookit/color"
 )
 
+// ResultWriter 命令行的结果输出器
+// 逐条输出扫描结果，结束时输出统计信息并保存结果文件
+// 结果写入 out（通常为标准输出），统计信息和错误写入日志
+type ResultWriter struct {
+	out        io.Writer // 结果输出位置
+	log        *Logger   // 日志
+	output     string    // 输出文件路径
+	silent     bool      // 静默模式，只输出命中结果
+	jsonOutput bool      // JSON 格式输出到终端
+	allResults []Result  // 所有扫描结果
+	hitResults []Result  // 命中指纹的结果
+}
+
+// NewResultWriter 创建结果输出器
+//
+// 参数：
+//   - out: 结果输出位置
+//   - log: 统计信息和错误的日志
+//   - output: 输出文件路径，为空则不保存
+//   - silent: 是否启用静默模式
+//   - jsonOutput: 是否以 JSON 格式输出到终端
+func NewResultWriter(out io.Writer, log *Logger, output string, silent, jsonOutput bool) *ResultWriter {
+	return &ResultWriter{
+		out:        out,
+		log:        log,
+		output:     output,
+		silent:     silent,
+		jsonOutput: jsonOutput,
+		allResults: []Result{},
+		hitResults: []Result{},
+	}
+}
+
+// Write 保存并输出单条结果
+//
+// 参数：
+//   - result: 扫描结果
+func (w *ResultWriter) Write(result Result) {
+	w.allResults = append(w.allResults, result)
+	if result.CMS != "" {
+		w.hitResults = append(w.hitResults, result)
+	}
+	w.printResult(result)
+}
+
+// Close 输出统计信息并保存结果文件
+func (w *ResultWriter) Close() {
+	// 输出扫描统计
+	w.log.Infof("Scanned: %d, Matched: %d", len(w.allResults), len(w.hitResults))
+
+	// 保存结果到文件
+	if w.output != "" {
+		if err := saveResults(w.output, w.allResults); err != nil {
+			w.log.Errorf("保存结果失败: %v", err)
+		}
+	}
+}
+
+// printResult 输出扫描结果
+// 根据模式选择不同的输出格式
+//
+// 参数：
+//   - result: 扫描结果
+func (w *ResultWriter) printResult(result Result) {
+	// JSON 输出模式
+	if w.jsonOutput {
+		data, _ := json.Marshal(result)
+		fmt.Fprintln(w.out, string(data))
+		return
+	}
+
+	// 静默模式：只输出命中指纹的结果
+	if w.silent {
+		if result.CMS != "" {
+			fmt.Fprintf(w.out, "%s [%s]\n", displayURL(result), result.CMS)
+		}
+		return
+	}
+
+	// 正常模式：httpx 风格输出
+	var parts []string
+	parts = append(parts, displayURL(result))
+	parts = append(parts, fmt.Sprintf("[%d]", result.StatusCode))
+	parts = append(parts, fmt.Sprintf("[%d]", result.Length))
+	if result.Server != "" {
+		parts = append(parts, fmt.Sprintf("[%s]", result.Server))
+	}
+	if result.Title != "" {
+		parts = append(parts, fmt.Sprintf("[%s]", result.Title))
+	}
+	if result.CMS != "" {
+		parts = append(parts, fmt.Sprintf("[%s]", result.CMS))
+	}
+
+	line := strings.Join(parts, " ")
+
+	// 命中指纹的结果用红色高亮显示
+	if result.CMS != "" {
+		line = color.RGBStyleFromString("237,64,35").Sprint(line)
+	}
+	fmt.Fprintln(w.out, line)
+}
+
+// displayURL 返回终端输出中的目标，虚拟主机结果附加使用的 Host
+func displayURL(result Result) string {
+	if result.VHost != "" {
+		return result.URL + " (" + result.VHost + ")"
+	}
+	return result.URL
+}
+
 // saveResults 保存扫描结果到 JSON 文件
 //
 // 参数：
 //   - filename: 输出文件路径
 //   - results: 指纹识别结果切片
-func saveResults(filename string, results []Result) {
+//
+// 返回：
+//   - error: 格式不支持或写入错误
+func saveResults(filename string, results []Result) error {
 	ext := strings.ToLower(filepath.Ext(filename))
 	if ext != ".json" {
-		fmt.Println("[!] Only JSON format is supported")
-		return
+		return fmt.Errorf("only JSON format is supported")
 	}
-	saveJSON(filename, results)
+	return saveJSON(filename, results)
 }
 
 // saveJSON 将结果保存为 JSON 格式文件
@@ -30,22 +146,16 @@ func saveResults(filename string, results []Result) {
 // 参数：
 //   - filename: 输出文件路径
 //   - results: 指纹识别结果切片
-func saveJSON(filename string, results []Result) {
+//
+// 返回：
+//   - error: 序列化或写入错误
+func saveJSON(filename string, results []Result) error {
 	// 使用缩进格式化 JSON，提高可读性
 	data, err := json.MarshalIndent(results, "", "  ")
 	if err != nil {
-		fmt.Println("[!] JSON error:", err)
-		return
-	}
-
-	// 创建输出文件
-	f, err := os.Create(filename)
-	if err != nil {
-		fmt.Println("[!] Create error:", err)
-		return
+		return err
 	}
-	defer f.Close()
 
 	// 写入 JSON 数据
-	f.Write(data)
+	return os.WriteFile(filename, data, 0644)
 }
@@ -0,0 +1,299 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件实现主动路径探测：
+// 1. 从已加载的规则中提取需要请求特定路径才能命中的指纹路径（Fingers send_data、FingerPrintHub 模板路径）
+// 2. 对每个目标请求这些路径，并将响应的识别结果归入目标本身
+// 3. 同一个源只探测一次，同一个源的路径按设定的并发数请求，探测请求按设定的速率发送
+//
+// Goby 规则只有 body、header、title 等被动匹配条件，没有请求路径，因此不作为探测路径的来源
+package pkg
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultProbeThreads 每个源默认的探测并发数
+const DefaultProbeThreads = 10
+
+// ProbeSources 规则路径的来源
+var ProbeSources = []string{FormatFingers, FormatFingerPrint}
+
+// fphTemplate FingerPrintHub 模板中与请求路径有关的字段
+type fphTemplate struct {
+	HTTP []struct {
+		Path []string `json:"path"`
+	} `json:"http"`
+}
+
+// rulePaths 返回规则中声明的主动探测路径，按来源分组、按字母顺序排列
+// 只在第一次调用时提取，没有开启主动探测时不产生额外开销
+//
+// 参数：
+//   - sources: 路径来源（见 ProbeSources），为空时返回所有来源的路径
+//
+// 返回：
+//   - []string: 去重后的路径列表
+func (rs *ruleSet) rulePaths(sources []string) []string {
+	rs.probeOnce.Do(func() {
+		rs.probePaths = make(map[string][]string)
+		seen := make(map[string]map[string]bool)
+		add := func(source, path string) {
+			if seen[source] == nil {
+				seen[source] = make(map[string]bool)
+			}
+			if path != "" && path != "/" && !seen[source][path] {
+				seen[source][path] = true
+				rs.probePaths[source] = append(rs.probePaths[source], path)
+			}
+		}
+
+		// Fingers 主动指纹：send_data 为请求路径
+		if impl := rs.engine.Fingers(); impl != nil {
+			for _, finger := range impl.HTTPFingersActiveFingers {
+				for _, rule := range finger.Rules {
+					add(FormatFingers, normalizeProbePath(rule.SendDataStr))
+				}
+			}
+		}
+
+		// FingerPrintHub 模板：path 为 {{BaseURL}}/xxx
+		if len(rs.fphData) > 0 {
+			var templates []fphTemplate
+			if data, err := readRuleData(rs.fphData); err == nil && json.Unmarshal(data, &templates) == nil {
+				for _, t := range templates {
+					for _, req := range t.HTTP {
+						for _, path := range req.Path {
+							add(FormatFingerPrint, normalizeProbePath(strings.TrimPrefix(path, "{{BaseURL}}")))
+						}
+					}
+				}
+			}
+		}
+
+		for _, paths := range rs.probePaths {
+			sort.Strings(paths)
+		}
+	})
+
+	if len(sources) == 0 {
+		sources = ProbeSources
+	}
+	var paths []string
+	seen := make(map[string]bool)
+	for _, source := range sources {
+		for _, path := range rs.probePaths[source] {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// ParseProbeSources 校验并规范化规则路径的来源，来源不区分大小写
+//
+// 参数：
+//   - sources: 路径来源列表，取值见 ProbeSources
+//
+// 返回：
+//   - []string: 小写的来源列表
+//   - error: 来源无效
+func ParseProbeSources(sources []string) ([]string, error) {
+	var result []string
+	for _, source := range sources {
+		source = strings.ToLower(strings.TrimSpace(source))
+		valid := false
+		for _, s := range ProbeSources {
+			valid = valid || s == source
+		}
+		if !valid {
+			return nil, fmt.Errorf("无效的探测路径来源 %q，支持 %s", source, strings.Join(ProbeSources, "、"))
+		}
+		result = append(result, source)
+	}
+	return result, nil
+}
+
+// normalizeProbePath 将规则中的请求路径规范化为以 / 开头的路径
+// 兼容 "GET /path HTTP/1.1" 形式的请求行，包含模板变量或无法识别的内容返回空字符串
+func normalizeProbePath(path string) string {
+	path = strings.TrimSpace(path)
+	if fields := strings.Fields(path); len(fields) >= 2 && !strings.HasPrefix(path, "/") {
+		path = fields[1]
+	}
+	if !strings.HasPrefix(path, "/") || strings.Contains(path, "{{") || strings.ContainsAny(path, " \r\n") {
+		return ""
+	}
+	return path
+}
+
+// LoadProbePaths 从文件加载主动探测路径，每行一个，忽略空行和 # 开头的注释
+// 不以 / 开头的路径自动补全
+//
+// 参数：
+//   - filename: 路径列表文件
+//
+// 返回：
+//   - []string: 路径列表
+//   - error: 文件读取错误
+func LoadProbePaths(filename string) ([]string, error) {
+	paths, err := readListFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	for i, path := range paths {
+		if !strings.HasPrefix(path, "/") {
+			paths[i] = "/" + path
+		}
+	}
+	return paths, nil
+}
+
+// probing 判断是否开启了主动探测
+func (s *Scanner) probing() bool {
+	return s.opts.Probe || len(s.opts.ProbePaths) > 0
+}
+
+// probeList 返回本次探测的路径：用户路径在前，规则路径（开启 Probe 时）在后，去重合并
+// 设置了 ProbeMax 时只保留前 ProbeMax 个路径
+func (s *Scanner) probeList(rs *ruleSet) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(list []string) {
+		for _, path := range list {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	add(s.opts.ProbePaths)
+	if s.opts.Probe {
+		add(rs.rulePaths(s.opts.ProbeSources))
+	}
+	if s.opts.ProbeMax > 0 && len(paths) > s.opts.ProbeMax {
+		paths = paths[:s.opts.ProbeMax]
+	}
+	return paths
+}
+
+// probe 对目标所在的源请求探测路径并识别指纹
+// 同一个源最多同时发送 ProbeThreads 个探测请求；与首页内容相同的响应（如把所有路径都返回首页的站点）直接跳过，避免重复识别
+//
+// 参数：
+//   - ctx: 上下文，取消后停止探测
+//   - resp: 目标首页的响应
+//
+// 返回：
+//   - []string: 探测路径上命中的指纹名称列表
+func (s *Scanner) probe(ctx context.Context, resp *Response) []string {
+	origin := responseOrigin(resp.URL)
+	mainBody := md5.Sum(resp.RawBody())
+
+	paths := make(chan string)
+	go func() {
+		defer close(paths)
+		for _, path := range s.probeList(s.rules.load()) {
+			if s.probeLimiter.wait(ctx) != nil {
+				return
+			}
+			select {
+			case paths <- path:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		matched []string
+	)
+	for i := 0; i < s.opts.ProbeThreads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				pr, err := s.fetch(ctx, []string{origin + path, "1"})
+				if err != nil || md5.Sum(pr.RawBody()) == mainBody {
+					continue
+				}
+				names := s.matchResponse(ctx, pr, nil, "")
+				mu.Lock()
+				matched = appendUnique(matched, names...)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	sort.Strings(matched)
+	return matched
+}
+
+// originSet 已探测过的源，在一次扫描的所有 worker 间共享
+type originSet struct {
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+// add 记录一个源，已存在时返回 false
+func (o *originSet) add(origin string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.seen[origin] {
+		return false
+	}
+	o.seen[origin] = true
+	return true
+}
+
+// rateLimiter 按固定间隔放行请求，所有 worker 共用
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration // 两次请求的最小间隔
+	next     time.Time     // 下一个请求可以发送的时间
+}
+
+// newRateLimiter 创建每秒最多 rate 个请求的限速器，rate 不大于 0 时返回 nil（不限速）
+func newRateLimiter(rate int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Second / time.Duration(rate)}
+}
+
+// wait 等待到可以发送下一个请求，ctx 取消时返回错误
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,273 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件实现扫描进度的计数和显示：
+// 1. 扫描器在 worker 中以 atomic 方式更新计数
+// 2. 终端上以进度条显示，非终端时定期输出 JSON 格式的进度行
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Progress 扫描进度计数器
+// 由扫描器在 worker 中并发更新，所有字段都通过 atomic 读写，可以在扫描过程中随时读取
+type Progress struct {
+	total    int64 // 任务总数（包括 JS 跳转产生的任务）
+	done     int64 // 已完成的任务数（包括请求失败的任务）
+	inFlight int64 // 正在处理的任务数
+	failed   int64 // 请求失败的任务数
+	hits     int64 // 命中指纹的结果数
+}
+
+// ProgressSnapshot 某一时刻的扫描进度
+type ProgressSnapshot struct {
+	Total    int64 `json:"total"`     // 任务总数
+	Done     int64 `json:"done"`      // 已完成的任务数
+	InFlight int64 `json:"in_flight"` // 正在处理的任务数
+	Failed   int64 `json:"failed"`    // 请求失败的任务数
+	Hits     int64 `json:"hits"`      // 命中指纹的结果数
+}
+
+// Snapshot 读取当前进度
+func (p *Progress) Snapshot() ProgressSnapshot {
+	return ProgressSnapshot{
+		Total:    atomic.LoadInt64(&p.total),
+		Done:     atomic.LoadInt64(&p.done),
+		InFlight: atomic.LoadInt64(&p.inFlight),
+		Failed:   atomic.LoadInt64(&p.failed),
+		Hits:     atomic.LoadInt64(&p.hits),
+	}
+}
+
+// addTotal 增加任务总数
+func (p *Progress) addTotal(n int) {
+	if p != nil {
+		atomic.AddInt64(&p.total, int64(n))
+	}
+}
+
+// taskStarted 记录一个开始处理的任务
+func (p *Progress) taskStarted() {
+	if p != nil {
+		atomic.AddInt64(&p.inFlight, 1)
+	}
+}
+
+// taskAborted 记录一个因扫描取消而未完成的任务
+func (p *Progress) taskAborted() {
+	if p != nil {
+		atomic.AddInt64(&p.inFlight, -1)
+	}
+}
+
+// taskFailed 记录一个请求失败的任务
+func (p *Progress) taskFailed() {
+	if p != nil {
+		atomic.AddInt64(&p.failed, 1)
+		atomic.AddInt64(&p.inFlight, -1)
+		atomic.AddInt64(&p.done, 1)
+	}
+}
+
+// taskDone 记录一个完成识别的任务
+func (p *Progress) taskDone(result Result) {
+	if p != nil {
+		if result.CMS != "" {
+			atomic.AddInt64(&p.hits, 1)
+		}
+		atomic.AddInt64(&p.inFlight, -1)
+		atomic.AddInt64(&p.done, 1)
+	}
+}
+
+// 进度显示配置
+const (
+	progressBarWidth   = 30                     // 进度条宽度
+	progressTTYRefresh = 200 * time.Millisecond // 终端进度条的刷新间隔
+)
+
+// ProgressReporter 扫描进度显示
+// 终端上在最后一行绘制进度条并定期刷新；非终端时定期输出一行 JSON，便于其他程序解析
+type ProgressReporter struct {
+	progress *Progress
+	out      io.Writer     // 进度输出位置，通常为标准错误
+	tty      bool          // out 是否为终端
+	interval time.Duration // 非终端时的输出间隔
+	start    time.Time
+
+	mu    sync.Mutex
+	shown bool // 终端上当前是否显示着进度条
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// progressLine 非终端时输出的进度行
+type progressLine struct {
+	Progress ProgressSnapshot `json:"progress"`
+	Elapsed  float64          `json:"elapsed"`       // 已用时间（秒）
+	Rate     float64          `json:"rate"`          // 每秒完成的任务数
+	ETA      *float64         `json:"eta,omitempty"` // 预计剩余时间（秒），无法估计时省略
+}
+
+// NewProgressReporter 创建进度显示并开始输出
+//
+// 参数：
+//   - progress: 扫描进度计数器
+//   - out: 进度输出位置
+//   - tty: out 是否为终端，决定输出进度条还是 JSON 进度行
+//   - interval: 非终端时的输出间隔
+//
+// 返回：
+//   - *ProgressReporter: 进度显示实例，结束时调用 Stop
+func NewProgressReporter(progress *Progress, out io.Writer, tty bool, interval time.Duration) *ProgressReporter {
+	r := &ProgressReporter{
+		progress: progress,
+		out:      out,
+		tty:      tty,
+		interval: interval,
+		start:    time.Now(),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if tty {
+		r.interval = progressTTYRefresh
+	}
+	go r.run()
+	return r
+}
+
+// Stop 停止输出进度
+// 终端上清除进度条，非终端时输出最后一行进度
+func (r *ProgressReporter) Stop() {
+	if r == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tty {
+		r.clear()
+	} else {
+		r.writeLine()
+	}
+}
+
+// Wrap 返回写入前先清除进度条的 Writer
+// 日志和扫描结果与进度条输出到同一个终端时，需要通过它写入，避免与进度条混在同一行
+func (r *ProgressReporter) Wrap(w io.Writer) io.Writer {
+	if r == nil || !r.tty {
+		return w
+	}
+	return &progressWriter{r: r, w: w}
+}
+
+// run 定期输出进度，直到 Stop
+func (r *ProgressReporter) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+
+		r.mu.Lock()
+		if r.tty {
+			r.draw()
+		} else {
+			r.writeLine()
+		}
+		r.mu.Unlock()
+	}
+}
+
+// stats 计算已用时间、速度和预计剩余时间
+func (r *ProgressReporter) stats(snap ProgressSnapshot) (time.Duration, float64, time.Duration, bool) {
+	elapsed := time.Since(r.start)
+	rate := float64(snap.Done) / elapsed.Seconds()
+	if snap.Done == 0 || rate <= 0 {
+		return elapsed, rate, 0, false
+	}
+	remaining := float64(snap.Total - snap.Done)
+	return elapsed, rate, time.Duration(remaining / rate * float64(time.Second)), true
+}
+
+// draw 在终端上重新绘制进度条，调用时需持有 r.mu
+func (r *ProgressReporter) draw() {
+	snap := r.progress.Snapshot()
+	_, rate, eta, ok := r.stats(snap)
+
+	percent := 0.0
+	if snap.Total > 0 {
+		percent = float64(snap.Done) / float64(snap.Total)
+	}
+	filled := int(percent * progressBarWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+
+	etaText := "--:--"
+	if ok {
+		etaText = formatDuration(eta)
+	}
+	fmt.Fprintf(r.out, "\r\033[K[%s] %d/%d %.0f%% | 进行中 %d | 失败 %d | 命中 %d | %.1f/s | ETA %s",
+		bar, snap.Done, snap.Total, percent*100, snap.InFlight, snap.Failed, snap.Hits, rate, etaText)
+	r.shown = true
+}
+
+// clear 清除终端上的进度条，调用时需持有 r.mu
+func (r *ProgressReporter) clear() {
+	if r.shown {
+		io.WriteString(r.out, "\r\033[K")
+		r.shown = false
+	}
+}
+
+// writeLine 输出一行 JSON 进度，调用时需持有 r.mu
+func (r *ProgressReporter) writeLine() {
+	snap := r.progress.Snapshot()
+	elapsed, rate, eta, ok := r.stats(snap)
+
+	line := progressLine{
+		Progress: snap,
+		Elapsed:  elapsed.Round(time.Millisecond).Seconds(),
+		Rate:     float64(int64(rate*100)) / 100,
+	}
+	if ok {
+		seconds := eta.Round(time.Second).Seconds()
+		line.ETA = &seconds
+	}
+	data, _ := json.Marshal(line)
+	fmt.Fprintf(r.out, "%s\n", data)
+}
+
+// progressWriter 写入前清除进度条的 Writer，进度条在下次刷新时重新绘制
+type progressWriter struct {
+	r *ProgressReporter
+	w io.Writer
+}
+
+// Write 清除进度条后写入
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	pw.r.mu.Lock()
+	defer pw.r.mu.Unlock()
+	pw.r.clear()
+	return pw.w.Write(p)
+}
+
+// formatDuration 将时长格式化为 hh:mm:ss 或 mm:ss
+func formatDuration(d time.Duration) string {
+	seconds := int64(d.Round(time.Second).Seconds())
+	h, m, s := seconds/3600, seconds/60%60, seconds%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%02d:%02d", m, s)
+}
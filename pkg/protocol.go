@@ -0,0 +1,147 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件实现 HTTP 协议能力检测：
+// 1. 记录扫描请求使用的 HTTP 版本
+// 2. HTTPS 目标以 h2 和 http/1.1 发起 ALPN 协商，记录服务端选择的协议
+// 3. HTTP 目标发送 Upgrade: h2c 请求，检测是否支持明文 HTTP/2 升级
+// 4. 解析 Alt-Svc 响应头，检测是否声明了 HTTP/3（QUIC）
+//
+// 协议能力有助于区分 CDN、反向代理和服务端软件
+package pkg
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ProtocolInfo 目标支持的 HTTP 协议
+type ProtocolInfo struct {
+	HTTP        string   `json:"http"`                   // 扫描请求使用的 HTTP 版本，如 HTTP/1.1
+	ALPN        string   `json:"alpn,omitempty"`         // 服务端在 ALPN 协商中选择的协议，不支持 ALPN 或非 HTTPS 时为空
+	ALPNOffered []string `json:"alpn_offered,omitempty"` // 协商时提供的协议
+	H2          bool     `json:"h2"`                     // 支持 HTTP/2（ALPN 选择 h2）
+	H2C         bool     `json:"h2c"`                    // 接受明文 HTTP/2 升级（Upgrade: h2c 返回 101）
+	H3          bool     `json:"h3"`                     // Alt-Svc 中声明了 HTTP/3
+	AltSvc      string   `json:"alt_svc,omitempty"`      // Alt-Svc 响应头
+}
+
+// alpnOffered ALPN 协商时提供的协议，与浏览器一致
+var alpnOffered = []string{"h2", "http/1.1"}
+
+// h2cSettings h2c 升级请求的 HTTP2-Settings 请求头（base64url 编码的 SETTINGS 帧内容）
+const h2cSettings = "AAMAAABkAARAAAAAAAIAAAAA"
+
+// detectProtocol 检测目标支持的 HTTP 协议
+// HTTPS 目标额外发送一次 ALPN 协商请求，HTTP 目标额外发送一次 h2c 升级请求
+//
+// 参数：
+//   - ctx: 上下文
+//   - resp: 目标首页的响应
+//
+// 返回：
+//   - *ProtocolInfo: 协议信息
+func (s *Scanner) detectProtocol(ctx context.Context, resp *Response) *ProtocolInfo {
+	info := &ProtocolInfo{HTTP: resp.Proto}
+	info.setAltSvc(resp.HeaderMap)
+
+	u, err := url.Parse(resp.URL)
+	if err != nil {
+		return info
+	}
+	switch u.Scheme {
+	case "https":
+		s.probeALPN(ctx, resp.URL, info)
+	case "http":
+		s.probeH2C(ctx, resp.URL, info)
+	}
+	return info
+}
+
+// probeALPN 以 h2 和 http/1.1 发起 ALPN 协商并请求 rawURL，记录服务端选择的协议
+func (s *Scanner) probeALPN(ctx context.Context, rawURL string, info *ProtocolInfo) {
+	client := newHTTPClient(s.proxies, s.opts.Resolver, s.opts.Timeout, func(transport *http.Transport) {
+		transport.ForceAttemptHTTP2 = true
+		transport.TLSClientConfig.NextProtos = alpnOffered
+	})
+	defer client.CloseIdleConnections()
+
+	req, err := s.opts.Request.newResourceRequest(ctx, rawURL, rawURL)
+	if err != nil {
+		return
+	}
+	// HTTP/2 禁止 Connection 请求头
+	req.Header.Del("Connection")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		s.opts.Logger.Debugf("%s ALPN 协商失败: %v (%s)", rawURL, err, time.Since(start).Round(time.Millisecond))
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	info.ALPNOffered = alpnOffered
+	if resp.TLS != nil {
+		info.ALPN = resp.TLS.NegotiatedProtocol
+	}
+	info.H2 = resp.ProtoMajor == 2
+	if info.AltSvc == "" {
+		info.setAltSvc(resp.Header)
+	}
+	s.opts.Logger.Debugf("%s ALPN: %q, %s (%s)", rawURL, info.ALPN, resp.Proto, time.Since(start).Round(time.Millisecond))
+}
+
+// probeH2C 发送 h2c 升级请求，服务端返回 101 Switching Protocols 时视为支持
+func (s *Scanner) probeH2C(ctx context.Context, rawURL string, info *ProtocolInfo) {
+	client := newHTTPClient(s.proxies, s.opts.Resolver, s.opts.Timeout)
+	defer client.CloseIdleConnections()
+
+	req, err := s.opts.Request.newResourceRequest(ctx, rawURL, rawURL)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Connection", "Upgrade, HTTP2-Settings")
+	req.Header.Set("Upgrade", "h2c")
+	req.Header.Set("HTTP2-Settings", h2cSettings)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		s.opts.Logger.Debugf("%s h2c 升级失败: %v (%s)", rawURL, err, time.Since(start).Round(time.Millisecond))
+		return
+	}
+	// 101 响应的 Body 为升级后的连接，不读取直接关闭
+	resp.Body.Close()
+
+	info.H2C = resp.StatusCode == http.StatusSwitchingProtocols && strings.EqualFold(resp.Header.Get("Upgrade"), "h2c")
+	if info.AltSvc == "" {
+		info.setAltSvc(resp.Header)
+	}
+	s.opts.Logger.Debugf("%s h2c 升级 -> %d (%s)", rawURL, resp.StatusCode, time.Since(start).Round(time.Millisecond))
+}
+
+// setAltSvc 记录 Alt-Svc 响应头，并判断是否声明了 HTTP/3
+func (info *ProtocolInfo) setAltSvc(header map[string][]string) {
+	values := http.Header(header).Values("Alt-Svc")
+	if len(values) == 0 {
+		return
+	}
+	info.AltSvc = strings.Join(values, ", ")
+	info.H3 = altSvcHasH3(info.AltSvc)
+}
+
+// altSvcHasH3 判断 Alt-Svc 中是否包含 HTTP/3 服务（h3 及 h3-29 等草案版本）
+// 形如 h3=":443"; ma=86400, h3-29=":443"; ma=86400
+func altSvcHasH3(altSvc string) bool {
+	for _, entry := range strings.Split(altSvc, ",") {
+		id := strings.TrimSpace(strings.SplitN(entry, "=", 2)[0])
+		if id == "h3" || strings.HasPrefix(id, "h3-") {
+			return true
+		}
+	}
+	return false
+}
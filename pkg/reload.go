@@ -0,0 +1,211 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件实现指纹的热加载：
+// 1. 重新读取指纹文件并构建新引擎，构建成功后原子替换，失败时继续使用原指纹
+// 2. 正在进行的识别使用开始时的指纹引擎，不受替换影响
+// 3. 轮询指纹文件的变化，自动重新加载
+package pkg
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chainreactors/fingers"
+)
+
+// ruleSet 一次加载得到的指纹引擎，创建后只读
+type ruleSet struct {
+	engine    *fingers.Engine // fingers 指纹识别引擎（内置指纹与自定义指纹）
+	arlEngine *ARLEngine      // ARL 指纹匹配引擎，没有 ARL 指纹时为 nil
+	jarmRules jarmRules       // JARM hash 到指纹名称的映射，没有 JARM 指纹时为 nil
+	stats     RuleStats       // 规则数量
+	fphData   []byte          // FingerPrintHub 指纹数据，用于提取模板中的请求路径
+
+	probeOnce  sync.Once           // 主动探测路径只在第一次使用时提取
+	probePaths map[string][]string // 规则中声明的主动探测路径，按来源（规则格式）分组
+}
+
+// ruleHolder 保存当前使用的指纹引擎
+// 同一个 New 创建的扫描器（包括 WithOptions 返回的副本）共用一个 ruleHolder，重新加载对所有副本生效
+type ruleHolder struct {
+	config  *CustomFingerConfig      // 指纹配置，重新加载时使用
+	log     *Logger                  // 加载信息的日志
+	hook    func(func() error) error // 包装每次加载，见 Options.RuleLoadHook
+	mu      sync.Mutex               // 保证同一时间只有一次重新加载
+	current atomic.Value             // *ruleSet
+}
+
+// RuleStats 已加载的规则数量
+type RuleStats struct {
+	Engines  map[string]int `json:"engines"`   // fingers 各引擎的规则数
+	ARL      int            `json:"arl"`       // ARL 规则数
+	JARM     int            `json:"jarm"`      // JARM 规则数
+	Total    int            `json:"total"`     // 规则总数（不含由其他引擎汇总的 favicon）
+	LoadedAt time.Time      `json:"loaded_at"` // 加载时间
+}
+
+// String 返回规则数量的简要描述，如 "ehole:1000 fingers:2000 arl:10"
+func (st RuleStats) String() string {
+	names := make([]string, 0, len(st.Engines))
+	for name := range st.Engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s:%d", name, st.Engines[name]))
+	}
+	if st.ARL > 0 {
+		parts = append(parts, fmt.Sprintf("arl:%d", st.ARL))
+	}
+	if st.JARM > 0 {
+		parts = append(parts, fmt.Sprintf("jarm:%d", st.JARM))
+	}
+	return strings.Join(parts, " ")
+}
+
+// newRuleHolder 加载指纹并创建 ruleHolder
+func newRuleHolder(config *CustomFingerConfig, log *Logger, hook func(func() error) error) (*ruleHolder, error) {
+	h := &ruleHolder{config: config, log: log, hook: hook}
+	rs, err := h.build()
+	if err != nil {
+		return nil, err
+	}
+	h.current.Store(rs)
+	return h, nil
+}
+
+// load 返回当前的指纹引擎
+func (h *ruleHolder) load() *ruleSet {
+	return h.current.Load().(*ruleSet)
+}
+
+// build 按配置加载指纹并统计规则数量
+func (h *ruleHolder) build() (*ruleSet, error) {
+	var engine *fingers.Engine
+	var arlEngine *ARLEngine
+	var data map[string][]byte
+	load := func() error {
+		var err error
+		engine, arlEngine, data, err = loadFingerprints(h.config, h.log)
+		return err
+	}
+
+	var err error
+	if h.hook != nil {
+		err = h.hook(load)
+	} else {
+		err = load()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stats := RuleStats{Engines: make(map[string]int), LoadedAt: time.Now()}
+	for name, impl := range engine.EnginesImpl {
+		stats.Engines[name] = impl.Len()
+		if name != "favicon" {
+			stats.Total += impl.Len()
+		}
+	}
+	if arlEngine != nil {
+		stats.ARL = len(arlEngine.fingerprints)
+		stats.Total += stats.ARL
+	}
+	var jarm jarmRules
+	if data[FormatJARM] != nil {
+		if jarm, stats.JARM, err = newJARMRules(data[FormatJARM]); err != nil {
+			return nil, fmt.Errorf("加载 JARM 指纹失败: %v", err)
+		}
+		stats.Total += stats.JARM
+	}
+	return &ruleSet{engine: engine, arlEngine: arlEngine, jarmRules: jarm, stats: stats, fphData: data[FormatFingerPrint]}, nil
+}
+
+// RuleStats 返回当前使用的规则数量
+func (s *Scanner) RuleStats() RuleStats {
+	return s.rules.load().stats
+}
+
+// Reload 按创建扫描器时的指纹配置重新加载指纹
+// 新引擎构建成功后原子替换，之后开始的识别使用新指纹，正在进行的识别仍使用原指纹完成；
+// 加载失败时继续使用原指纹
+//
+// 返回：
+//   - RuleStats: 重新加载后的规则数量，失败时为原指纹的规则数量
+//   - error: 加载错误
+func (s *Scanner) Reload() (RuleStats, error) {
+	h := s.rules
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rs, err := h.build()
+	if err != nil {
+		return h.load().stats, err
+	}
+	h.current.Store(rs)
+	return rs.stats, nil
+}
+
+// WatchRules 定期检查指纹文件，发现新增、删除或修改时重新加载
+// 阻塞直到 ctx 取消，通常在单独的 goroutine 中运行
+//
+// 参数：
+//   - ctx: 上下文，取消后停止检查
+//   - interval: 检查间隔
+//   - notify: 每次重新加载后的回调，参数与 Reload 的返回值一致
+func (s *Scanner) WatchRules(ctx context.Context, interval time.Duration, notify func(RuleStats, error)) {
+	last := ruleFilesSignature(s.rules.config)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		sig := ruleFilesSignature(s.rules.config)
+		if sig == last {
+			continue
+		}
+		last = sig
+		stats, err := s.Reload()
+		if notify != nil {
+			notify(stats, err)
+		}
+	}
+}
+
+// ruleFilesSignature 计算指纹文件列表及其大小、修改时间的摘要
+// 路径错误（如文件被删除）也计入摘要，文件恢复后会再次触发重新加载
+func ruleFilesSignature(config *CustomFingerConfig) [md5.Size]byte {
+	if config == nil {
+		return [md5.Size]byte{}
+	}
+
+	var b strings.Builder
+	var paths []string
+	files, err := collectRuleFiles(config)
+	if err != nil {
+		fmt.Fprintf(&b, "error %v\n", err)
+	} else {
+		paths = files.all()
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&b, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return md5.Sum([]byte(b.String()))
+}
@@ -0,0 +1,35 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestRuleLoadHook New 和 Reload 都经由 RuleLoadHook 加载指纹，钩子返回的错误作为加载错误
+func TestRuleLoadHook(t *testing.T) {
+	calls := 0
+	var hookErr error
+	hook := func(load func() error) error {
+		calls++
+		if err := load(); err != nil {
+			return err
+		}
+		return hookErr
+	}
+
+	s, err := New(Options{Rules: &CustomFingerConfig{NoDefault: true}, RuleLoadHook: hook})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := s.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("hook called %d times, want 2", calls)
+	}
+
+	hookErr = errors.New("hook failed")
+	if _, err := s.WithOptions(Options{}).Reload(); err != hookErr {
+		t.Errorf("Reload error = %v, want %v", err, hookErr)
+	}
+}
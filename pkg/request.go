@@ -0,0 +1,362 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件实现请求配置：请求方法、请求头、Cookie、请求体、User-Agent 和认证信息
+// 页面请求、主动探测请求和 favicon 等资源请求使用同一份配置
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// shiroCookie 用于检测 Shiro 框架的 Cookie，Shiro 会在响应中返回 rememberMe=deleteMe
+const shiroCookie = "rememberMe=me"
+
+// RequestConfig 请求配置
+// 零值（或 nil）与默认行为一致：GET 请求、Accept: */*、随机内置 User-Agent、携带 rememberMe=me Cookie
+//
+// 指定了请求模板时，模板作为每个请求的基础：其余配置按以下顺序覆盖模板中的同名部分：
+// Method、Body 覆盖模板的请求方法和请求体，UserAgents 覆盖模板的 User-Agent，Headers 覆盖所有同名请求头；
+// 模板的 Cookie 请求头、Cookies、Cookie 文件中匹配的 Cookie 和 Shiro 检测 Cookie 合并发送
+type RequestConfig struct {
+	Method        string            `yaml:"method" json:"method,omitempty"`                   // 请求方法，默认 GET；只用于页面和主动探测请求，favicon 等资源始终使用 GET
+	Headers       map[string]string `yaml:"headers" json:"headers,omitempty"`                 // 额外的请求头，覆盖同名的默认请求头
+	Cookies       string            `yaml:"cookies" json:"cookies,omitempty"`                 // Cookie，形如 "a=1; b=2"
+	Body          string            `yaml:"body" json:"body,omitempty"`                       // 请求体；只用于页面和主动探测请求
+	UserAgents    []string          `yaml:"user_agents" json:"user_agents,omitempty"`         // User-Agent 列表，每次请求随机选择一个，为空时使用内置列表
+	NoShiroCookie bool              `yaml:"no_shiro_cookie" json:"no_shiro_cookie,omitempty"` // 不携带 rememberMe=me Cookie
+	BasicAuth     string            `yaml:"basic_auth" json:"basic_auth,omitempty"`           // HTTP Basic 认证，形如 "user:pass"
+	BearerToken   string            `yaml:"bearer_token" json:"bearer_token,omitempty"`       // Bearer Token，与 BasicAuth 同时指定时使用 BasicAuth
+
+	TemplateFile  string `yaml:"template" json:"-"`   // 原始请求模板文件，由 LoadRequestConfig 加载到 Template
+	CookieJarFile string `yaml:"cookie_jar" json:"-"` // Netscape 格式的 Cookie 文件，由 LoadRequestConfig 加载到 Jar
+
+	Template *RequestTemplate `yaml:"-" json:"-"` // 原始请求模板，为 nil 时不使用模板
+	Jar      http.CookieJar   `yaml:"-" json:"-"` // 按域名和路径附加的会话 Cookie，为 nil 时不使用
+}
+
+// LoadRequestConfig 从 YAML 文件加载请求配置
+//
+// 文件格式：
+//
+//	method: POST
+//	headers:
+//	  Authorization: Bearer xxx
+//	cookies: "session=abc"
+//	body: "a=1"
+//	user_agents:
+//	  - "Mozilla/5.0 ..."
+//	no_shiro_cookie: true
+//	basic_auth: "user:pass"
+//	template: request.txt
+//	cookie_jar: cookies.txt
+//
+// template 和 cookie_jar 为相对路径时相对于配置文件所在目录
+//
+// 参数：
+//   - filename: 配置文件路径
+//
+// 返回：
+//   - *RequestConfig: 请求配置
+//   - error: 文件读取或解析错误
+func LoadRequestConfig(filename string) (*RequestConfig, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &RequestConfig{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("解析请求配置 %s 失败: %v", filename, err)
+	}
+
+	dir := filepath.Dir(filename)
+	if config.TemplateFile != "" {
+		if config.Template, err = LoadRequestTemplate(resolvePath(dir, config.TemplateFile)); err != nil {
+			return nil, err
+		}
+	}
+	if config.CookieJarFile != "" {
+		if config.Jar, err = LoadCookieJar(resolvePath(dir, config.CookieJarFile)); err != nil {
+			return nil, err
+		}
+	}
+	return config, config.Validate()
+}
+
+// resolvePath 将相对路径解析为相对于 dir 的路径
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// LoadUserAgents 从文件加载 User-Agent 列表，每行一个，忽略空行和 # 开头的注释
+//
+// 参数：
+//   - filename: User-Agent 列表文件
+//
+// 返回：
+//   - []string: User-Agent 列表
+//   - error: 文件读取错误，文件中没有 User-Agent 时同样返回错误
+func LoadUserAgents(filename string) ([]string, error) {
+	agents, err := readListFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(agents) == 0 {
+		return nil, fmt.Errorf("%s 中没有 User-Agent", filename)
+	}
+	return agents, nil
+}
+
+// ParseHeader 解析 "Name: value" 形式的请求头
+//
+// 参数：
+//   - header: 请求头字符串
+//
+// 返回：
+//   - string: 请求头名称
+//   - string: 请求头的值
+//   - error: 格式错误
+func ParseHeader(header string) (string, string, error) {
+	idx := strings.Index(header, ":")
+	if idx <= 0 {
+		return "", "", fmt.Errorf("无效的请求头 %q，格式应为 \"Name: value\"", header)
+	}
+	name := strings.TrimSpace(header[:idx])
+	if name == "" || strings.ContainsAny(name, " \t\r\n") {
+		return "", "", fmt.Errorf("无效的请求头名称 %q", name)
+	}
+	return name, strings.TrimSpace(header[idx+1:]), nil
+}
+
+// Validate 检查请求配置，请求方法统一转为大写
+func (c *RequestConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	c.Method = strings.ToUpper(strings.TrimSpace(c.Method))
+	if c.Method != "" && strings.ContainsAny(c.Method, " \t\r\n") {
+		return fmt.Errorf("无效的请求方法 %q", c.Method)
+	}
+	for name := range c.Headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("无效的请求头名称 %q", name)
+		}
+	}
+	if c.BasicAuth != "" && !strings.Contains(c.BasicAuth, ":") {
+		return fmt.Errorf("Basic 认证应为 \"user:pass\" 形式")
+	}
+	return nil
+}
+
+// newRequest 按配置创建页面或主动探测请求
+// 指定了请求模板时，请求地址按模板的请求行生成，模板请求体中的占位符替换为该地址的对应部分
+//
+// 参数：
+//   - ctx: 上下文
+//   - rawURL: 请求地址
+//
+// 返回：
+//   - *http.Request: 请求
+//   - error: URL 或模板错误
+func (c *RequestConfig) newRequest(ctx context.Context, rawURL string) (*http.Request, error) {
+	content := ""
+	if c != nil {
+		content = c.Body
+		if c.Template != nil {
+			target, err := c.Template.url(rawURL)
+			if err != nil {
+				return nil, err
+			}
+			rawURL = target
+			if content == "" {
+				u, err := url.Parse(target)
+				if err != nil {
+					return nil, err
+				}
+				content = c.Template.render(c.Template.Body, u)
+			}
+		}
+	}
+
+	var body io.Reader
+	if content != "" {
+		body = strings.NewReader(content)
+	}
+	req, err := http.NewRequestWithContext(ctx, c.method(), rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		// 与 curl -d 一致，未指定时按表单提交
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	c.apply(req, true)
+	return req, nil
+}
+
+// method 返回页面请求使用的请求方法：Method、模板的请求方法，默认 GET
+func (c *RequestConfig) method() string {
+	switch {
+	case c == nil:
+		return "GET"
+	case c.Method != "":
+		return c.Method
+	case c.Template != nil && c.Template.Method != "":
+		return c.Template.Method
+	}
+	return "GET"
+}
+
+// newResourceRequest 按配置创建 favicon 等资源的 GET 请求，不使用请求方法和请求体
+// 资源与页面同源时与页面请求一致；跨域的资源（如 CDN 上的图标）只使用 User-Agent 和 Cookie 文件中
+// 按域名匹配的 Cookie，不发送认证信息、自定义请求头（包括固定的 Host）和 Cookies 配置
+//
+// 参数：
+//   - ctx: 上下文
+//   - rawURL: 资源地址
+//   - pageURL: 引用资源的页面地址
+//
+// 返回：
+//   - *http.Request: 请求
+//   - error: URL 错误
+func (c *RequestConfig) newResourceRequest(ctx context.Context, rawURL, pageURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.apply(req, isSameOrigin(rawURL, pageURL))
+	return req, nil
+}
+
+// apply 设置请求头、Cookie 和 User-Agent
+// 依次设置默认请求头、模板请求头、User-Agent 列表、认证信息和自定义请求头，后设置的覆盖先设置的；
+// Host 请求头设置为请求的 Host。sameOrigin 为 false 时只设置默认请求头、User-Agent 列表和 Cookie 文件中的 Cookie
+func (c *RequestConfig) apply(req *http.Request, sameOrigin bool) {
+	req.Header.Set("Accept", "*/*")
+	req.Header.Set("Connection", "close")
+	req.Header.Set("User-Agent", randomUA())
+
+	if c == nil {
+		if sameOrigin {
+			req.Header.Set("Cookie", shiroCookie)
+		}
+		return
+	}
+
+	set := func(name, value string) {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			return
+		}
+		req.Header.Set(name, value)
+	}
+
+	if sameOrigin && c.Template != nil {
+		for _, h := range c.Template.Headers {
+			set(h[0], c.Template.render(h[1], req.URL))
+		}
+	}
+	if len(c.UserAgents) > 0 {
+		req.Header.Set("User-Agent", c.UserAgents[rand.Intn(len(c.UserAgents))])
+	}
+	if sameOrigin {
+		if c.BasicAuth != "" {
+			user, pass := c.BasicAuth, ""
+			if idx := strings.Index(user, ":"); idx >= 0 {
+				user, pass = user[:idx], user[idx+1:]
+			}
+			req.SetBasicAuth(user, pass)
+		} else if c.BearerToken != "" {
+			req.Header.Set("Authorization", "Bearer "+c.BearerToken)
+		}
+
+		// 按名称排序，保证同一配置发出的请求一致
+		names := make([]string, 0, len(c.Headers))
+		for name := range c.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			set(name, c.Headers[name])
+		}
+	}
+
+	if cookie := c.cookie(req, sameOrigin); cookie != "" {
+		req.Header.Set("Cookie", cookie)
+	}
+}
+
+// cookie 合并 Cookie 请求头、Cookies 配置、Cookie 文件中匹配的 Cookie 和 Shiro 检测 Cookie
+// 同名 Cookie 以先出现的为准，已经指定了 rememberMe 时不再追加；
+// sameOrigin 为 false 时只使用 Cookie 文件中匹配的 Cookie
+func (c *RequestConfig) cookie(req *http.Request, sameOrigin bool) string {
+	var parts []string
+	if sameOrigin {
+		for _, s := range []string{req.Header.Get("Cookie"), c.Cookies} {
+			if s = strings.Trim(strings.TrimSpace(s), ";"); s != "" {
+				parts = append(parts, s)
+			}
+		}
+	}
+	if c.Jar != nil {
+		for _, ck := range c.Jar.Cookies(req.URL) {
+			if !hasCookie(strings.Join(parts, "; "), ck.Name) {
+				parts = append(parts, ck.Name+"="+ck.Value)
+			}
+		}
+	}
+
+	if sameOrigin && !c.NoShiroCookie && !hasCookie(strings.Join(parts, "; "), "rememberMe") {
+		parts = append(parts, shiroCookie)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// isSameOrigin 判断两个地址的协议、主机名和端口是否相同，未指定端口时使用协议的默认端口
+func isSameOrigin(a, b string) bool {
+	u, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	v, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Scheme, v.Scheme) &&
+		strings.EqualFold(u.Hostname(), v.Hostname()) &&
+		urlPort(u) == urlPort(v)
+}
+
+// urlPort 返回 URL 的端口，未指定时 https 为 443，其他为 80
+func urlPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if strings.EqualFold(u.Scheme, "https") {
+		return "443"
+	}
+	return "80"
+}
+
+// hasCookie 判断 Cookie 字符串中是否包含指定名称的 Cookie
+func hasCookie(cookie, name string) bool {
+	for _, part := range strings.Split(cookie, ";") {
+		if kv := strings.SplitN(strings.TrimSpace(part), "=", 2); kv[0] == name {
+			return true
+		}
+	}
+	return false
+}
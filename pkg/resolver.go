@@ -0,0 +1,390 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件实现 DNS 解析：
+// 1. 使用指定的 DNS 服务器（UDP 或 TCP）解析目标域名，多个服务器轮换使用
+// 2. hosts 文件中的域名直接使用指定的 IP，不发送 DNS 请求
+// 3. 缓存解析结果（包括 CDN 检测使用的 CNAME），同一域名的并发解析只发送一次请求
+// 4. 作为 HTTP 传输层的拨号函数，扫描中的所有连接都经过同一个解析器
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DNS 缓存时间
+const (
+	dnsCacheTTL    = 10 * time.Minute // 解析成功的缓存时间
+	dnsNegativeTTL = time.Minute      // 解析失败的缓存时间
+)
+
+// Resolver DNS 解析器
+// 可以被多个扫描器和 goroutine 同时使用，缓存在所有使用者间共享
+type Resolver struct {
+	servers  []dnsServer         // DNS 服务器，为空时使用系统 DNS
+	hosts    map[string][]string // hosts 覆盖：域名 -> IP 列表
+	resolver *net.Resolver       // 实际发送 DNS 请求的解析器
+	dialer   *net.Dialer         // 连接目标使用的拨号器
+	next     uint32              // 下一个使用的 DNS 服务器
+
+	mu     sync.Mutex
+	cache  map[string]*dnsEntry // 域名 -> 解析结果
+	cnames map[string]*dnsEntry // 域名 -> CNAME 查询结果
+}
+
+// dnsServer DNS 服务器
+type dnsServer struct {
+	network string // udp 或 tcp
+	addr    string // IP:端口
+}
+
+// dnsEntry 缓存的解析结果，done 关闭前解析仍在进行
+type dnsEntry struct {
+	done    chan struct{}
+	ips     []string
+	err     error
+	expires time.Time
+}
+
+// ParseResolver 解析 DNS 服务器地址
+// 支持 8.8.8.8、8.8.8.8:53、udp://8.8.8.8、tcp://10.0.0.1:5353 和 [2001:db8::1]:53，默认 UDP 53 端口
+//
+// 参数：
+//   - raw: DNS 服务器地址
+//
+// 返回：
+//   - string: 协议（udp 或 tcp）
+//   - string: IP:端口
+//   - error: 地址无效，DNS 服务器必须是 IP
+func ParseResolver(raw string) (string, string, error) {
+	raw = strings.TrimSpace(raw)
+	network := "udp"
+	if idx := strings.Index(raw, "://"); idx >= 0 {
+		network = strings.ToLower(raw[:idx])
+		raw = raw[idx+3:]
+	}
+	if network != "udp" && network != "tcp" {
+		return "", "", fmt.Errorf("不支持的 DNS 协议 %q，支持 udp、tcp", network)
+	}
+
+	host, port := raw, "53"
+	if h, p, err := net.SplitHostPort(raw); err == nil {
+		host, port = h, p
+	}
+	host = strings.Trim(host, "[]")
+	if net.ParseIP(host) == nil {
+		return "", "", fmt.Errorf("无效的 DNS 服务器 %q，应为 IP 或 IP:端口", raw)
+	}
+	return network, net.JoinHostPort(host, port), nil
+}
+
+// LoadResolvers 从文件加载 DNS 服务器列表，每行一个，忽略空行和 # 开头的注释
+//
+// 参数：
+//   - filename: DNS 服务器列表文件
+//
+// 返回：
+//   - []string: DNS 服务器地址列表（未校验，由 NewResolver 校验）
+//   - error: 文件读取错误，文件中没有 DNS 服务器时同样返回错误
+func LoadResolvers(filename string) ([]string, error) {
+	lines, err := readListFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%s 中没有 DNS 服务器", filename)
+	}
+	return lines, nil
+}
+
+// LoadHostsFile 加载 hosts 格式的文件，每行为 "IP 域名 [域名...]"，# 之后为注释
+// 同一域名出现多次时保留所有 IP
+//
+// 参数：
+//   - filename: hosts 文件
+//
+// 返回：
+//   - map[string][]string: 域名（小写）到 IP 列表的映射
+//   - error: 文件读取错误或 IP 无效
+func LoadHostsFile(filename string) (map[string][]string, error) {
+	lines, err := readListFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make(map[string][]string)
+	for _, line := range lines {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 || net.ParseIP(fields[0]) == nil {
+			return nil, fmt.Errorf("%s: 无效的 hosts 记录 %q，格式应为 \"IP 域名\"", filename, line)
+		}
+		for _, name := range fields[1:] {
+			name = normalizeHostname(name)
+			hosts[name] = appendUnique(hosts[name], fields[0])
+		}
+	}
+	return hosts, nil
+}
+
+// readListFile 读取每行一项的列表文件，忽略空行和 # 开头的注释
+func readListFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// NewResolver 创建 DNS 解析器
+//
+// 参数：
+//   - servers: DNS 服务器地址列表，格式见 ParseResolver，为空时使用系统 DNS
+//   - hosts: hosts 覆盖，域名到 IP 列表的映射（见 LoadHostsFile），可为 nil
+//
+// 返回：
+//   - *Resolver: DNS 解析器
+//   - error: DNS 服务器地址无效，servers 为空时始终为 nil
+func NewResolver(servers []string, hosts map[string][]string) (*Resolver, error) {
+	r := &Resolver{
+		hosts:    make(map[string][]string),
+		resolver: net.DefaultResolver,
+		dialer:   &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		cache:    make(map[string]*dnsEntry),
+		cnames:   make(map[string]*dnsEntry),
+	}
+	for name, ips := range hosts {
+		r.hosts[normalizeHostname(name)] = ips
+	}
+	for _, raw := range servers {
+		network, addr, err := ParseResolver(raw)
+		if err != nil {
+			return nil, err
+		}
+		r.servers = append(r.servers, dnsServer{network: network, addr: addr})
+	}
+
+	if len(r.servers) > 0 {
+		// PreferGo 保证使用 Go 的解析器，Dial 忽略系统配置的 DNS 服务器，轮换使用指定的服务器；
+		// 返回的连接不是 PacketConn（TCP）时，Go 的解析器自动使用 TCP 格式
+		r.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				server := r.servers[int(atomic.AddUint32(&r.next, 1)-1)%len(r.servers)]
+				return (&net.Dialer{Timeout: 5 * time.Second}).DialContext(ctx, server.network, server.addr)
+			},
+		}
+	}
+	return r, nil
+}
+
+// LookupHost 解析域名，返回 IP 列表（IPv4 在前）
+// 依次查找 hosts 覆盖和缓存，都没有时发送 DNS 请求；IP 直接返回，r 为 nil 时使用系统 DNS 且不缓存
+//
+// 参数：
+//   - ctx: 上下文
+//   - host: 域名或 IP
+//
+// 返回：
+//   - []string: IP 列表
+//   - error: 解析失败
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	host = normalizeHostname(host)
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{ip.String()}, nil
+	}
+	if r == nil {
+		return net.DefaultResolver.LookupHost(ctx, host)
+	}
+	if ips, ok := r.hosts[host]; ok {
+		return ips, nil
+	}
+	return r.cached(ctx, r.cache, host, r.lookupIP)
+}
+
+// LookupCNAME 查询域名的 CNAME，返回 CNAME 链最终指向的域名（小写，不带末尾的点）
+// 域名没有 CNAME、是 IP 或在 hosts 覆盖中时返回空字符串；r 为 nil 时使用系统 DNS 且不缓存
+//
+// 参数：
+//   - ctx: 上下文
+//   - host: 域名
+//
+// 返回：
+//   - string: CNAME
+//   - error: 查询失败
+func (r *Resolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	host = normalizeHostname(host)
+	if net.ParseIP(host) != nil {
+		return "", nil
+	}
+	var values []string
+	var err error
+	if r == nil {
+		values, err = lookupCNAME(ctx, net.DefaultResolver, host)
+	} else if _, ok := r.hosts[host]; ok {
+		return "", nil
+	} else {
+		values, err = r.cached(ctx, r.cnames, host, func(ctx context.Context, host string) ([]string, error) {
+			return lookupCNAME(ctx, r.resolver, host)
+		})
+	}
+	if err != nil || len(values) == 0 {
+		return "", err
+	}
+	return values[0], nil
+}
+
+// cached 从 cache 中返回 host 的查询结果，没有或已过期时调用 lookup 查询并缓存
+// 同一域名的并发查询只调用一次 lookup；lookup 不使用调用方的 ctx，避免一个请求取消导致同时等待该域名的其他请求失败
+func (r *Resolver) cached(ctx context.Context, cache map[string]*dnsEntry, host string, lookup func(context.Context, string) ([]string, error)) ([]string, error) {
+	r.mu.Lock()
+	entry, ok := cache[host]
+	if ok && entry.isDone() && time.Now().After(entry.expires) {
+		ok = false
+	}
+	if !ok {
+		entry = &dnsEntry{done: make(chan struct{})}
+		cache[host] = entry
+		r.mu.Unlock()
+
+		lookupCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		entry.ips, entry.err = lookup(lookupCtx, host)
+		cancel()
+		entry.expires = time.Now().Add(dnsCacheTTL)
+		if entry.err != nil {
+			entry.expires = time.Now().Add(dnsNegativeTTL)
+		}
+		close(entry.done)
+	} else {
+		r.mu.Unlock()
+	}
+
+	select {
+	case <-entry.done:
+		return entry.ips, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// lookupIP 发送 DNS 请求解析域名，返回 IP 列表（IPv4 在前）
+func (r *Resolver) lookupIP(ctx context.Context, host string) ([]string, error) {
+	addrs, err := r.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("%s 没有 IP 地址", host)
+	}
+
+	ips := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP.String())
+	}
+	sort.SliceStable(ips, func(i, j int) bool {
+		return net.ParseIP(ips[i]).To4() != nil && net.ParseIP(ips[j]).To4() == nil
+	})
+	return ips, nil
+}
+
+// lookupCNAME 查询 CNAME，没有 CNAME（结果为域名本身）时返回空列表
+func lookupCNAME(ctx context.Context, resolver *net.Resolver, host string) ([]string, error) {
+	cname, err := resolver.LookupCNAME(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if cname = normalizeHostname(cname); cname == "" || cname == host {
+		return nil, nil
+	}
+	return []string{cname}, nil
+}
+
+// isDone 判断解析是否已完成
+func (e *dnsEntry) isDone() bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// DialContext 解析地址中的域名后依次连接每个 IP，直到连接成功
+// 用作 http.Transport 的拨号函数
+func (r *Resolver) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ip := range ips {
+		var conn net.Conn
+		if conn, err = r.dialer.DialContext(ctx, network, net.JoinHostPort(ip, port)); err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+// resolveAddr 解析 host:port 中的域名，返回第一个 IP:port
+func (r *Resolver) resolveAddr(ctx context.Context, addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	ips, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(ips[0], port), nil
+}
+
+// resolveIPs 返回 URL 中主机的 IP 列表，记录在结果中
+// 使用代理时目标由代理解析，本地解析的结果不一定是实际连接的 IP，且会泄露 DNS 请求，因此不解析；解析失败时返回 nil
+func (s *Scanner) resolveIPs(ctx context.Context, rawURL string) []string {
+	if s.proxies != nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	ips, _ := s.opts.Resolver.LookupHost(ctx, u.Hostname())
+	return ips
+}
+
+// normalizeHostname 将域名转为小写并去掉末尾的点
+func normalizeHostname(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
@@ -0,0 +1,173 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件负责指纹规则文件的读取和格式识别
+// 所有格式（包括 JSON）统一解析为 yaml.Node，以便保留规则所在的行号
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 支持的指纹格式
+const (
+	FormatEHole       = "ehole"          // EHole JSON
+	FormatGoby        = "goby"           // Goby JSON
+	FormatWappalyzer  = "wappalyzer"     // Wappalyzer JSON
+	FormatFingers     = "fingers"        // chainreactors fingers JSON / YAML
+	FormatFingerPrint = "fingerprinthub" // FingerPrintHub v4 JSON
+	FormatARL         = "arl"            // ARL YAML
+	FormatJARM        = "jarm"           // JARM hash YAML / JSON
+)
+
+// RuleFormats 所有支持的指纹格式
+var RuleFormats = []string{FormatEHole, FormatGoby, FormatWappalyzer, FormatFingers, FormatFingerPrint, FormatARL, FormatJARM}
+
+// readRuleFile 读取指纹文件内容
+// gzip 压缩的文件（.json.gz）会自动解压
+func readRuleFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		return gzipDecompress(data)
+	}
+	return data, nil
+}
+
+// parseRuleNode 将指纹文件内容解析为 yaml.Node
+// JSON 是 YAML 的子集，因此同一套解析逻辑可以处理所有格式
+//
+// 参数：
+//   - data: 文件内容
+//
+// 返回：
+//   - *yaml.Node: 文档的根节点（mapping 或 sequence）
+//   - error: 解析错误
+func parseRuleNode(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, fmt.Errorf("empty rule file")
+	}
+	return doc.Content[0], nil
+}
+
+// detectNodeFormat 根据根节点结构识别指纹格式
+//
+// 识别规则：
+//  1. mapping 且包含 fingerprint 字段：EHole
+//  2. mapping 且包含 apps 字段：Wappalyzer
+//  3. sequence 则根据第一条规则的字段判断：
+//     rule 为字符串是 ARL，包含 logic 是 Goby，包含 id 和 info 是 FingerPrintHub，rule 为列表是 Fingers，包含 jarm 是 JARM
+//
+// 返回：
+//   - 格式名称，无法识别时返回空字符串
+func detectNodeFormat(root *yaml.Node) string {
+	switch root.Kind {
+	case yaml.MappingNode:
+		if mapValue(root, "fingerprint") != nil {
+			return FormatEHole
+		}
+		if mapValue(root, "apps") != nil {
+			return FormatWappalyzer
+		}
+	case yaml.SequenceNode:
+		for _, item := range root.Content {
+			if item.Kind != yaml.MappingNode {
+				continue
+			}
+			rule := mapValue(item, "rule")
+			switch {
+			case rule != nil && rule.Kind == yaml.ScalarNode:
+				return FormatARL
+			case mapValue(item, "logic") != nil:
+				return FormatGoby
+			case mapValue(item, "id") != nil && mapValue(item, "info") != nil:
+				return FormatFingerPrint
+			case rule != nil && rule.Kind == yaml.SequenceNode:
+				return FormatFingers
+			case mapValue(item, "jarm") != nil:
+				return FormatJARM
+			}
+		}
+	}
+	return ""
+}
+
+// DetectRuleFormat 识别指纹文件的格式
+//
+// 参数：
+//   - path: 指纹文件路径
+//
+// 返回：
+//   - string: 格式名称（FormatEHole 等）
+//   - error: 读取、解析错误或无法识别的格式
+func DetectRuleFormat(path string) (string, error) {
+	data, err := readRuleFile(path)
+	if err != nil {
+		return "", err
+	}
+	root, err := parseRuleNode(data)
+	if err != nil {
+		return "", err
+	}
+	format := detectNodeFormat(root)
+	if format == "" {
+		return "", fmt.Errorf("无法识别的指纹格式: %s", path)
+	}
+	return format, nil
+}
+
+// mapValue 获取 mapping 节点中指定 key 的值节点
+// 节点不是 mapping 或 key 不存在时返回 nil
+func mapValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// mapString 获取 mapping 节点中指定 key 的字符串值
+func mapString(node *yaml.Node, key string) string {
+	v := mapValue(node, key)
+	if v == nil || v.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return strings.TrimSpace(v.Value)
+}
+
+// scalarNodes 返回标量节点本身或序列节点中的所有标量节点
+// 用于兼容字段既可以写成字符串也可以写成字符串数组的格式（如 Wappalyzer），null 视为没有值
+func scalarNodes(node *yaml.Node) []*yaml.Node {
+	if node == nil {
+		return nil
+	}
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return nil
+		}
+		return []*yaml.Node{node}
+	case yaml.SequenceNode:
+		var nodes []*yaml.Node
+		for _, n := range node.Content {
+			if n.Kind == yaml.ScalarNode && n.Tag != "!!null" {
+				nodes = append(nodes, n)
+			}
+		}
+		return nodes
+	}
+	return nil
+}
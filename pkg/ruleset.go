@@ -0,0 +1,414 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件负责指纹文件的查找和合并：
+// 1. 展开文件、目录和通配符，自动识别每个文件的格式
+// 2. 将同一格式的多个文件合并为一份规则数据
+package pkg
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/chainreactors/fingers/resources"
+	"gopkg.in/yaml.v3"
+)
+
+// bundledRulesDir 内置指纹目录名
+const bundledRulesDir = "fingerprints"
+
+// ruleExts 目录中会被当作指纹文件加载的扩展名
+var ruleExts = map[string]bool{
+	".json": true,
+	".yaml": true,
+	".yml":  true,
+	".gz":   true,
+}
+
+// BundledRulesDir 查找随程序发布的 fingerprints/ 目录
+// 依次查找当前工作目录和可执行文件所在目录
+//
+// 返回：
+//   - 目录路径，不存在时返回空字符串
+func BundledRulesDir() string {
+	dirs := []string{bundledRulesDir}
+	if exe, err := os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Join(filepath.Dir(exe), bundledRulesDir))
+	}
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
+
+// expandRulePaths 展开指纹文件参数
+// 支持单个文件、目录（递归查找 .json/.yaml/.yml/.gz 文件）和通配符
+//
+// 参数：
+//   - pattern: 文件、目录或通配符
+//
+// 返回：
+//   - []string: 按路径排序的文件列表
+//   - error: 路径不存在或通配符没有匹配任何文件
+func expandRulePaths(pattern string) ([]string, error) {
+	matches := []string{pattern}
+	if strings.ContainsAny(pattern, "*?[") {
+		var err error
+		matches, err = filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("没有匹配的指纹文件: %s", pattern)
+		}
+	}
+
+	var files []string
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, match)
+			continue
+		}
+		err = filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && ruleExts[strings.ToLower(filepath.Ext(path))] {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ruleFiles 按格式分组的指纹文件
+type ruleFiles struct {
+	bundled map[string][]string // 随程序发布的指纹目录中的文件，作为同格式的默认指纹
+	custom  map[string][]string // 自定义指纹文件，合并到默认指纹上
+	skipped []string            // 无法识别格式而跳过的文件
+}
+
+// collectRuleFiles 根据配置收集各格式的指纹文件
+// BundledDir 和 RulesDirs 中的文件自动识别格式，格式参数（--ehole 等）中的文件按指定格式加载，
+// 无法识别格式的文件跳过；同一文件只加载一次，NoDefault 时不加载 BundledDir
+// 自定义指纹文件按加载顺序排列：RulesDirs 在前，格式参数在后，按名称覆盖时后加载的优先
+//
+// 参数：
+//   - config: 自定义指纹配置
+//
+// 返回：
+//   - *ruleFiles: 按格式分组的指纹文件
+//   - error: 路径错误
+func collectRuleFiles(config *CustomFingerConfig) (*ruleFiles, error) {
+	files := &ruleFiles{bundled: make(map[string][]string), custom: make(map[string][]string)}
+	seen := make(map[string]bool)
+
+	add := func(dst map[string][]string, format string, patterns []string) error {
+		for _, pattern := range patterns {
+			paths, err := expandRulePaths(pattern)
+			if err != nil {
+				return err
+			}
+			for _, path := range paths {
+				abs, err := filepath.Abs(path)
+				if err != nil {
+					abs = path
+				}
+				if seen[abs] {
+					continue
+				}
+				seen[abs] = true
+
+				f := format
+				if f == "" {
+					if f, err = DetectRuleFormat(path); err != nil {
+						files.skipped = append(files.skipped, path)
+						continue
+					}
+				}
+				dst[f] = append(dst[f], path)
+			}
+		}
+		return nil
+	}
+
+	if config.BundledDir != "" && !config.NoDefault {
+		if err := add(files.bundled, "", []string{config.BundledDir}); err != nil {
+			return nil, err
+		}
+	}
+
+	formats := []struct {
+		format   string
+		patterns []string
+	}{
+		{"", config.RulesDirs},
+		{FormatEHole, config.EHole},
+		{FormatGoby, config.Goby},
+		{FormatWappalyzer, config.Wappalyzer},
+		{FormatFingers, config.Fingers},
+		{FormatFingerPrint, config.FingerPrint},
+		{FormatARL, config.ARL},
+		{FormatJARM, config.JARM},
+	}
+	for _, f := range formats {
+		if err := add(files.custom, f.format, f.patterns); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// all 返回所有指纹文件，包括跳过的文件
+func (f *ruleFiles) all() []string {
+	var paths []string
+	for _, list := range f.bundled {
+		paths = append(paths, list...)
+	}
+	for _, list := range f.custom {
+		paths = append(paths, list...)
+	}
+	return append(paths, f.skipped...)
+}
+
+// loadRuleFormat 加载一种格式的指纹：默认指纹与自定义指纹合并
+// 指纹目录（BundledDir）中有该格式的文件时，以这些文件作为默认指纹，替换 fingers 库的内置指纹，
+// 目录中修正过的内置指纹副本因此不会与内置指纹重复加载；NoDefault 时没有默认指纹
+//
+// 参数：
+//   - format: 指纹格式
+//   - config: 自定义指纹配置
+//   - files: collectRuleFiles 收集的指纹文件
+//
+// 返回：
+//   - []byte: 合并后的指纹数据，没有任何指纹时为 nil
+//   - []string: 实际加载的自定义指纹文件
+//   - error: 读取或解析错误
+func loadRuleFormat(format string, config *CustomFingerConfig, files *ruleFiles) ([]byte, []string, error) {
+	var base []byte
+	if !config.NoDefault {
+		if paths := files.bundled[format]; len(paths) > 0 {
+			var err error
+			if base, _, err = mergeRuleFiles(format, nil, paths, false); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			base = builtinRuleData(format)
+		}
+	}
+
+	merged, loaded, err := mergeRuleFiles(format, base, files.custom[format], config.Override)
+	if err != nil {
+		return nil, nil, err
+	}
+	if merged == nil {
+		return base, nil, nil
+	}
+	return merged, loaded, nil
+}
+
+// builtinRuleData 返回 fingers 库内置的各格式指纹数据（gzip 压缩）
+// 只读取 resources 包中的数据，不做任何修改
+func builtinRuleData(format string) []byte {
+	switch format {
+	case FormatEHole:
+		return resources.EholeData
+	case FormatGoby:
+		return resources.GobyData
+	case FormatWappalyzer:
+		return resources.WappalyzerData
+	case FormatFingers:
+		return resources.FingersHTTPData
+	case FormatFingerPrint:
+		return resources.FingerprinthubWebData
+	}
+	return nil
+}
+
+// decodeRuleData 将 JSON 或 YAML 格式的指纹内容解码为通用结构
+// 优先使用 JSON 解码，大文件的解码速度明显快于 YAML
+func decodeRuleData(data []byte) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err == nil {
+		return v, nil
+	}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// mergeRuleFiles 将同一格式的多个指纹文件合并到基础指纹上
+// 与基础指纹完全一致的文件（如内置指纹的副本）会被跳过
+//
+// 参数：
+//   - format: 指纹格式
+//   - base: 基础指纹数据（内置指纹，可为 gzip 压缩），为空表示没有基础指纹
+//   - paths: 指纹文件列表，按顺序合并
+//   - override: 是否按名称覆盖，见 mergeRuleValue
+//
+// 返回：
+//   - []byte: 合并后的 JSON 数据，没有加载任何文件时返回 nil
+//   - []string: 实际加载的文件
+//   - error: 读取或解析错误
+func mergeRuleFiles(format string, base []byte, paths []string, override bool) ([]byte, []string, error) {
+	var baseRaw []byte
+	if len(base) > 0 {
+		var err error
+		if baseRaw, err = readRuleData(base); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var merged interface{}
+	var loaded []string
+	for _, path := range paths {
+		data, err := readRuleFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if baseRaw != nil && md5.Sum(baseRaw) == md5.Sum(data) {
+			continue
+		}
+		v, err := decodeRuleData(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", path, err)
+		}
+
+		// 有需要合并的文件时才解码基础指纹
+		if merged == nil && baseRaw != nil {
+			if merged, err = decodeRuleData(baseRaw); err != nil {
+				return nil, nil, err
+			}
+		}
+		merged, err = mergeRuleValue(format, merged, v, override)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", path, err)
+		}
+		loaded = append(loaded, path)
+	}
+
+	if len(loaded) == 0 {
+		return nil, nil, nil
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, loaded, nil
+}
+
+// readRuleData 返回指纹数据的原始内容，gzip 压缩的数据自动解压
+func readRuleData(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		return gzipDecompress(data)
+	}
+	return data, nil
+}
+
+// mergeRuleValue 合并两份解码后的指纹数据
+// 根节点为列表时拼接；为映射时逐个字段合并：
+// 列表字段（EHole 的 fingerprint）拼接，映射字段（Wappalyzer 的 apps）按 key 合并，同名时后者覆盖前者
+//
+// 参数：
+//   - format: 指纹格式，用于获取规则名称
+//   - dst: 已合并的数据，为 nil 时直接返回 src
+//   - src: 新加载的数据
+//   - override: 为 true 时，列表中与 src 同名的已有规则会被删除（按名称覆盖），否则保留（叠加）
+func mergeRuleValue(format string, dst, src interface{}, override bool) (interface{}, error) {
+	if dst == nil {
+		return src, nil
+	}
+	switch d := dst.(type) {
+	case []interface{}:
+		s, ok := src.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("文件结构与同格式的其他文件不一致")
+		}
+		return mergeRuleList(format, d, s, override), nil
+	case map[string]interface{}:
+		s, ok := src.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("文件结构与同格式的其他文件不一致")
+		}
+		for k, sv := range s {
+			switch dv := d[k].(type) {
+			case []interface{}:
+				if list, ok := sv.([]interface{}); ok {
+					d[k] = mergeRuleList(format, dv, list, override)
+					continue
+				}
+			case map[string]interface{}:
+				if m, ok := sv.(map[string]interface{}); ok {
+					for mk, mv := range m {
+						dv[mk] = mv
+					}
+					continue
+				}
+			}
+			d[k] = sv
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("无法识别的文件结构")
+}
+
+// mergeRuleList 合并两个规则列表
+// override 为 true 时先删除 dst 中与 src 同名的规则
+func mergeRuleList(format string, dst, src []interface{}, override bool) []interface{} {
+	if override {
+		names := make(map[string]bool)
+		for _, item := range src {
+			if name := ruleName(format, item); name != "" {
+				names[name] = true
+			}
+		}
+		kept := dst[:0]
+		for _, item := range dst {
+			if !names[ruleName(format, item)] {
+				kept = append(kept, item)
+			}
+		}
+		dst = kept
+	}
+	return append(dst, src...)
+}
+
+// ruleName 返回单条规则的显示名称，用于按名称覆盖
+// EHole 为 cms，FingerPrintHub 为 info.name，ARL 为去掉后缀的 name，其余格式为 name
+func ruleName(format string, item interface{}) string {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	var name string
+	switch format {
+	case FormatEHole:
+		name, _ = m["cms"].(string)
+	case FormatFingerPrint:
+		if info, ok := m["info"].(map[string]interface{}); ok {
+			name, _ = info["name"].(string)
+		}
+	case FormatARL:
+		name, _ = m["name"].(string)
+		name = extractARLName(name)
+	default:
+		name, _ = m["name"].(string)
+	}
+	return name
+}
@@ -0,0 +1,86 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// bundledDir 仓库中随程序发布的指纹目录
+var bundledDir = filepath.Join("..", bundledRulesDir)
+
+// bundledFPHTemplates 指纹目录中 fingerprinthub_web.json 的 web 模板数，与 fingers 库内置的模板数一致
+const bundledFPHTemplates = 2888
+
+// TestBundledRulesReplaceBuiltin 指纹目录中的文件替换同格式的内置指纹，不与内置指纹重复加载
+func TestBundledRulesReplaceBuiltin(t *testing.T) {
+	fph := bundledFPHTemplates
+	arl := len(mustARLRules(t))
+
+	tests := []struct {
+		name   string
+		config *CustomFingerConfig
+	}{
+		{"bundled dir", &CustomFingerConfig{BundledDir: bundledDir}},
+		// 同一目录再通过 RulesDirs 指定时只加载一次
+		{"bundled dir and rules dir", &CustomFingerConfig{BundledDir: bundledDir, RulesDirs: []string{bundledDir}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			h, err := newRuleHolder(tt.config, NewLogger(&logs, LevelInfo), nil)
+			if err != nil {
+				t.Fatalf("newRuleHolder: %v", err)
+			}
+			stats := h.load().stats
+			if got := stats.Engines["fingerprinthub"]; got != fph {
+				t.Errorf("fingerprinthub templates = %d, want %d", got, fph)
+			}
+			if stats.ARL != arl {
+				t.Errorf("arl rules = %d, want %d", stats.ARL, arl)
+			}
+			if strings.Contains(logs.String(), "自定义") {
+				t.Errorf("bundled files logged as custom rules:\n%s", logs.String())
+			}
+		})
+	}
+
+	// 不使用指纹目录时为内置指纹，数量与指纹目录中的副本一致
+	h, err := newRuleHolder(nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h.load().stats.Engines["fingerprinthub"]; got != fph {
+		t.Errorf("builtin fingerprinthub templates = %d, want %d", got, fph)
+	}
+
+	// NoDefault 时忽略指纹目录
+	files, err := collectRuleFiles(&CustomFingerConfig{BundledDir: bundledDir, NoDefault: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paths := files.all(); len(paths) != 0 {
+		t.Errorf("NoDefault loaded %v", paths)
+	}
+}
+
+// mustARLRules 读取指纹目录中的 ARL 规则
+func mustARLRules(t *testing.T) []ARLFingerprint {
+	t.Helper()
+	data, err := readRuleFile(filepath.Join(bundledDir, "ARL.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := decodeRuleData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := json.Marshal(v)
+	var rules []ARLFingerprint
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		t.Fatal(err)
+	}
+	return rules
+}
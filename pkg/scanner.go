@@ -2,203 +2,327 @@
 // 本文件是指纹扫描器的核心实现，负责：
 // 1. 初始化 fingers 指纹识别引擎
 // 2. 并发扫描目标 URL
-// 3. 收集和输出扫描结果
+// 3. 以 channel 的形式返回扫描结果
+//
+// 扫描器不依赖任何包级可变状态，不会退出进程，也不会修改标准输出，
+// 可以作为库直接嵌入其他程序使用：
+//
+//	scanner, err := pkg.New(pkg.Options{Thread: 20})
+//	if err != nil {
+//		return err
+//	}
+//	for result := range scanner.Scan(ctx, []string{"https://example.com"}) {
+//		fmt.Println(result.URL, result.CMS)
+//	}
 package pkg
 
 import (
-	"encoding/json"
-	"fmt"
-	"os"
+	"context"
+	"net/http"
+	"sort"
 	"strings"
 	"sync"
+	"time"
+)
 
-	"github.com/chainreactors/fingers"
-	"github.com/gookit/color"
+// 扫描器默认配置
+const (
+	DefaultThread  = 50               // 默认并发线程数
+	DefaultTimeout = 10 * time.Second // 默认 HTTP 请求超时时间
 )
 
 // Result 扫描结果结构体
 // 保存单个 URL 的扫描结果，用于输出和 JSON 导出
 type Result struct {
-	URL        string `json:"url"`         // 目标 URL
-	CMS        string `json:"cms"`         // 检测到的 CMS/框架，多个用逗号分隔
-	Server     string `json:"server"`      // 服务器信息
-	StatusCode int    `json:"status_code"` // HTTP 状态码
-	Length     int    `json:"length"`      // 响应体长度
-	Title      string `json:"title"`       // 页面标题
+	URL        string        `json:"url"`                // 目标 URL
+	CMS        string        `json:"cms"`                // 检测到的 CMS/框架，多个用逗号分隔
+	Server     string        `json:"server"`             // 服务器信息
+	StatusCode int           `json:"status_code"`        // HTTP 状态码
+	Length     int           `json:"length"`             // 响应体长度
+	Title      string        `json:"title"`              // 页面标题
+	VHost      string        `json:"vhost,omitempty"`    // 虚拟主机识别时使用的 Host，只出现在虚拟主机结果中
+	IPs        []string      `json:"ips,omitempty"`      // 目标域名解析到的 IP（IPv4 在前），解析失败时为空
+	TLS        *TLSInfo      `json:"tls,omitempty"`      // TLS 连接信息和服务器证书，只出现在 HTTPS 结果中
+	JARM       string        `json:"jarm,omitempty"`     // TLS 服务端的 JARM hash，只在开启 JARM 探测时出现
+	CDN        *CDNInfo      `json:"cdn,omitempty"`      // CDN 信息，只在开启 CDN 检测且检测到 CDN 时出现
+	WAF        *WAFInfo      `json:"waf,omitempty"`      // WAF 信息，只在开启 WAF 检测且检测到 WAF 时出现
+	Protocol   *ProtocolInfo `json:"protocol,omitempty"` // 支持的 HTTP 协议（ALPN、h2c、HTTP/3），只在开启协议检测时出现
+}
+
+// Options 扫描器配置
+type Options struct {
+	Thread   int                 // 并发线程数，默认 DefaultThread
+	Timeout  time.Duration       // HTTP 请求超时时间，默认 DefaultTimeout
+	Proxy    string              // 代理地址，支持 http、https、socks5、socks5h 和认证信息，为空则不使用代理
+	Proxies  *ProxyPool          // 代理池，不为 nil 时优先于 Proxy：请求在代理间轮换，代理失效时自动切换
+	Resolver *Resolver           // DNS 解析器（自定义 DNS 服务器、hosts 覆盖、缓存），为 nil 时使用系统 DNS
+	Rules    *CustomFingerConfig // 指纹配置，为 nil 时只使用内置指纹
+	Logger   *Logger             // 日志，为 nil 时不输出
+	Progress *Progress           // 扫描进度计数器，为 nil 时不统计
+	Request  *RequestConfig      // 请求方法、请求头、Cookie 等配置，为 nil 时使用默认配置
+
+	// RuleLoadHook 包装每次指纹加载（New 和重新加载），load 执行实际的加载，为 nil 时直接加载
+	// fingers 库构建 FingerPrintHub 引擎时会直接打印到标准输出，调用方可以在这里按需处理
+	RuleLoadHook func(load func() error) error
+
+	Probe      bool     // 主动探测规则中声明的路径（如 /nacos/、/actuator），命中结果归入目标本身
+	ProbePaths []string // 额外的主动探测路径，不为空时同样开启探测
+	ProbeRate  int      // 探测请求每秒上限（所有 worker 合计），0 为不限制
+
+	ProbeSources []string // 规则路径的来源（见 ProbeSources），为空时使用全部来源
+	ProbeMax     int      // 每个源最多探测的路径数（用户路径优先），0 为不限制
+	ProbeThreads int      // 每个源的探测并发数，默认 DefaultProbeThreads
+
+	VHosts      []string // 候选域名，不为空时对 IP 目标逐一以这些域名作为 Host 和 SNI 请求，独立的虚拟主机单独输出结果
+	CertTargets bool     // 将目标证书 SAN 中的域名作为新目标扫描（只扩展一层）
+	JARM        bool     // 对 HTTPS 主页面进行 JARM 探测（10 次 TLS 握手），记录 hash 并与 JARM 指纹匹配
+	Protocols   bool     // 检测主页面支持的 HTTP 协议：HTTPS 目标协商 ALPN，HTTP 目标尝试 h2c 升级，解析 Alt-Svc 中的 HTTP/3
+
+	CDN        bool      // 检测目标是否经过 CDN（CNAME、IP 段、响应头）
+	CDNRanges  CDNRanges // 额外的 CDN IP 段，与内置的 IP 段一起使用
+	WAF        bool      // 检测主页面是否有 WAF（响应特征，并发送一次带恶意参数的请求对比）
+	ExcludeCDN bool      // 不输出经过 CDN 的结果，开启时同时开启 CDN 检测
+	ExcludeWAF bool      // 不输出有 WAF 的结果，开启时同时开启 WAF 检测
 }
 
 // Scanner 指纹扫描器
-// 负责管理扫描任务队列、并发控制和结果收集
+// 创建后只读（指纹引擎除外，可通过 Reload 原子替换），可以被多个 goroutine 同时使用，每次 Scan 调用相互独立
 type Scanner struct {
-	queue        *Queue          // URL 任务队列
-	wg           sync.WaitGroup  // 等待组，用于同步所有扫描 goroutine
-	mu           sync.Mutex      // 互斥锁，保护结果切片的并发写入
-	thread       int             // 并发线程数
-	output       string          // 输出文件路径
-	proxy        string          // 代理地址
-	silent       bool            // 静默模式，只输出命中结果
-	jsonOutput   bool            // JSON 格式输出到终端
-	allResults   []Result        // 所有扫描结果
-	hitResults   []Result        // 命中指纹的结果
-	engine       *fingers.Engine // fingers 指纹识别引擎（默认指纹）
-	customEngine *fingers.Engine // 自定义指纹引擎
-	arlEngine    *ARLEngine      // ARL 指纹匹配引擎
-	engines      []string        // 启用的指纹引擎列表
+	opts         Options        // 扫描器配置
+	client       *http.Client   // HTTP 客户端
+	rules        *ruleHolder    // 指纹引擎，可通过 Reload 原子替换
+	getResource  resourceGetter // favicon 等附属资源的获取函数
+	probeLimiter *rateLimiter   // 主动探测请求的限速器，为 nil 时不限速
+	proxies      *ProxyPool     // 代理池，为 nil 时不使用代理
 }
 
-// NewScanner 创建扫描器实例
-// 初始化 fingers 引擎和任务队列
+// New 创建扫描器实例
+// 加载指纹并初始化 fingers 引擎，引擎初始化较慢，同一个扫描器应尽量复用
 //
 // 参数：
-//   - urls: 待扫描的 URL 列表
-//   - thread: 并发线程数
-//   - output: 输出文件路径，为空则不保存
-//   - proxy: 代理地址，为空则不使用代理
-//   - timeout: HTTP 请求超时时间（秒）
-//   - silent: 是否启用静默模式
-//   - jsonOutput: 是否以 JSON 格式输出到终端
-//   - customConfig: 自定义指纹配置
+//   - opts: 扫描器配置
 //
 // 返回：
 //   - *Scanner: 扫描器实例
-func NewScanner(urls []string, thread int, output, proxy string, timeout int, silent, jsonOutput bool, customConfig *CustomFingerConfig) *Scanner {
-	// 检查是否禁用默认指纹
-	noDefault := customConfig != nil && customConfig.NoDefault
-
-	// 检查是否有自定义指纹文件
-	hasCustomFingers := customConfig != nil && (customConfig.EHole != "" || customConfig.Goby != "" ||
-		customConfig.Wappalyzer != "" || customConfig.Fingers != "" || customConfig.FingerPrint != "")
-
-	var engine *fingers.Engine
-	var customEngine *fingers.Engine
-	var err error
-
-	// 初始化默认指纹引擎（除非禁用）
-	if !noDefault {
-		if silent || jsonOutput {
-			oldStdout := os.Stdout
-			os.Stdout, _ = os.Open(os.DevNull)
-			engine, err = fingers.NewEngine()
-			os.Stdout = oldStdout
-		} else {
-			engine, err = fingers.NewEngine()
-		}
-		if err != nil {
-			fmt.Printf("[!] 初始化默认指纹引擎失败: %v\n", err)
-			os.Exit(1)
+//   - error: 代理地址无效或指纹加载错误
+func New(opts Options) (*Scanner, error) {
+	if opts.Proxy != "" {
+		if _, err := ParseProxy(opts.Proxy); err != nil {
+			return nil, err
 		}
 	}
+	rules, err := newRuleHolder(opts.Rules, opts.Logger, opts.RuleLoadHook)
+	if err != nil {
+		return nil, err
+	}
 
-	// 初始化自定义指纹引擎（如果有自定义指纹）
-	if hasCustomFingers {
-		// 加载自定义指纹文件
-		if err := LoadCustomFingerprints(customConfig, silent || jsonOutput); err != nil {
-			fmt.Printf("[!] 加载自定义指纹失败: %v\n", err)
-			os.Exit(1)
-		}
+	s := &Scanner{rules: rules}
+	return s.WithOptions(opts), nil
+}
 
-		// 确定要启用的自定义引擎
-		var customEngines []string
-		if customConfig.EHole != "" {
-			customEngines = append(customEngines, "ehole")
-		}
-		if customConfig.Goby != "" {
-			customEngines = append(customEngines, "goby")
-		}
-		if customConfig.Wappalyzer != "" {
-			customEngines = append(customEngines, "wappalyzer")
-		}
-		if customConfig.Fingers != "" {
-			customEngines = append(customEngines, "fingers")
-		}
-		if customConfig.FingerPrint != "" {
-			customEngines = append(customEngines, "fingerprinthub")
-		}
-		customEngines = append(customEngines, "favicon")
-
-		if silent || jsonOutput {
-			oldStdout := os.Stdout
-			os.Stdout, _ = os.Open(os.DevNull)
-			customEngine, err = fingers.NewEngine(customEngines...)
-			os.Stdout = oldStdout
-		} else {
-			customEngine, err = fingers.NewEngine(customEngines...)
-		}
-		if err != nil {
-			fmt.Printf("[!] 初始化自定义指纹引擎失败: %v\n", err)
-			os.Exit(1)
-		}
-	} else if noDefault {
-		// 只有 --no-default 但没有自定义指纹，需要清空默认数据
-		if err := LoadCustomFingerprints(customConfig, silent || jsonOutput); err != nil {
-			fmt.Printf("[!] 处理指纹配置失败: %v\n", err)
-			os.Exit(1)
+// WithOptions 返回共享指纹引擎、使用新扫描参数的扫描器
+// 用于为不同的扫描任务设置不同的线程数、超时、代理和进度计数器，而无需重新初始化指纹引擎；
+// opts 中的 Rules 和 RuleLoadHook 只在 New 中使用，这里会被忽略；重新加载指纹时的日志始终输出到 New 时指定的 Logger
+//
+// 参数：
+//   - opts: 扫描器配置
+//
+// 返回：
+//   - *Scanner: 新的扫描器实例
+func (s *Scanner) WithOptions(opts Options) *Scanner {
+	if opts.Thread <= 0 {
+		opts.Thread = DefaultThread
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.ProbeThreads <= 0 {
+		opts.ProbeThreads = DefaultProbeThreads
+	}
+	opts.CDN = opts.CDN || opts.ExcludeCDN
+	opts.WAF = opts.WAF || opts.ExcludeWAF
+	if opts.Resolver == nil {
+		// 默认使用系统 DNS，同一扫描器内缓存解析结果；NewResolver 只在 DNS 服务器地址无效时返回错误，这里不会失败
+		opts.Resolver, _ = NewResolver(nil, nil)
+	}
+	proxies := opts.Proxies
+	if proxies == nil && opts.Proxy != "" {
+		var err error
+		if proxies, err = NewProxyPool([]string{opts.Proxy}, ProxyRoundRobin, opts.Logger); err != nil {
+			// 代理地址无效时所有请求都返回错误，而不是绕过代理直接连接
+			proxies = &ProxyPool{err: err}
 		}
 	}
 
-	// 创建扫描器实例
-	s := &Scanner{
-		queue:        NewQueue(),
-		thread:       thread,
-		output:       output,
-		proxy:        proxy,
-		silent:       silent,
-		jsonOutput:   jsonOutput,
-		allResults:   []Result{},
-		hitResults:   []Result{},
-		engine:       engine,
-		customEngine: customEngine,
+	return &Scanner{
+		opts:         opts,
+		client:       newHTTPClient(proxies, opts.Resolver, opts.Timeout),
+		rules:        s.rules,
+		getResource:  logGetter(opts.Logger, httpGetter(newHTTPClient(proxies, opts.Resolver, faviconTimeout), opts.Request)),
+		probeLimiter: newRateLimiter(opts.ProbeRate),
+		proxies:      proxies,
 	}
+}
 
-	// 初始化 ARL 引擎（如果指定了 ARL 指纹文件）
-	if customConfig != nil && customConfig.ARL != "" {
-		arlEngine, err := NewARLEngine(customConfig.ARL)
-		if err != nil {
-			fmt.Printf("[!] 加载 ARL 指纹失败: %v\n", err)
-			os.Exit(1)
-		}
-		s.arlEngine = arlEngine
-		if !silent && !jsonOutput {
-			fmt.Printf("[*] 已加载 ARL 指纹: %s (%d 条规则)\n", customConfig.ARL, len(arlEngine.fingerprints))
+// Scan 并发扫描目标 URL
+// 结果通过返回的 channel 逐条发送，所有目标扫描完成或 ctx 取消后 channel 关闭
+//
+// 参数：
+//   - ctx: 上下文，取消后停止扫描
+//   - targets: 目标 URL 列表
+//
+// 返回：
+//   - <-chan Result: 扫描结果
+func (s *Scanner) Scan(ctx context.Context, targets []string) <-chan Result {
+	// 将 URL 添加到任务队列
+	// task[0] 为 URL，task[1] 为任务类型（"0" 表示主页面，"1" 表示 JS 跳转页面，"2" 表示虚拟主机，task[2] 为候选域名，
+	// "3" 表示从证书 SAN 中发现的目标）
+	queue := NewQueue()
+	state := newScanState(queue)
+	for _, url := range targets {
+		queue.Push([]string{url, "0"})
+		if s.opts.CertTargets {
+			state.targets.add(responseOrigin(url))
 		}
 	}
+	s.opts.Progress.addTotal(len(targets))
 
-	// 设置 HTTP 请求超时时间
-	Timeout = timeout
+	return s.dispatch(func(results chan<- Result) {
+		s.scan(ctx, state, results)
+	})
+}
 
-	// 将 URL 添加到任务队列
-	// task[0] 为 URL，task[1] 为任务类型（"0" 表示主页面）
-	for _, url := range urls {
-		s.queue.Push([]string{url, "0"})
-	}
+// Match 对已保存的响应进行离线指纹识别
+// 识别流程与 Scan 完全一致，但不发送任何网络请求：
+// favicon 和 manifest 只从导入的响应中查找，data: URI 在本地解码
+//
+// 参数：
+//   - ctx: 上下文，取消后停止识别
+//   - responses: 从文件导入的响应列表
+//
+// 返回：
+//   - <-chan Result: 每个响应一条识别结果
+func (s *Scanner) Match(ctx context.Context, responses []*Response) <-chan Result {
+	get := offlineGetter(responses)
+	s.opts.Progress.addTotal(len(responses))
+
+	ch := make(chan *Response)
+	go func() {
+		defer close(ch)
+		for _, resp := range responses {
+			select {
+			case ch <- resp:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return s.dispatch(func(results chan<- Result) {
+		for resp := range ch {
+			s.opts.Progress.taskStarted()
+			result := s.analyze(ctx, resp, true, get)
+			s.opts.Progress.taskDone(result)
+			if !sendResult(ctx, results, result) {
+				return
+			}
+		}
+	})
+}
 
-	return s
+// MatchPassive 对代理流量中导入的响应进行被动指纹识别
+// 与 Match 一样不发送任何请求，但按源（scheme://host:port）去重：
+// 同一源的所有响应分别识别后合并为一条结果，状态码、标题等取自该源的首页响应
+//
+// 参数：
+//   - ctx: 上下文，取消后停止识别
+//   - responses: 从 HAR / Burp XML 导入的响应列表
+//
+// 返回：
+//   - <-chan Result: 每个源一条识别结果
+func (s *Scanner) MatchPassive(ctx context.Context, responses []*Response) <-chan Result {
+	get := offlineGetter(responses)
+	groups := groupByOrigin(responses)
+	s.opts.Progress.addTotal(len(groups))
+
+	ch := make(chan []*Response)
+	go func() {
+		defer close(ch)
+		for _, group := range groups {
+			select {
+			case ch <- group:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return s.dispatch(func(results chan<- Result) {
+		for group := range ch {
+			s.opts.Progress.taskStarted()
+			result := s.analyzeOrigin(ctx, group, get)
+			s.opts.Progress.taskDone(result)
+			if !sendResult(ctx, results, result) {
+				return
+			}
+		}
+	})
 }
 
-// Run 启动扫描
-// 创建多个 goroutine 并发执行扫描任务
-func (s *Scanner) Run() {
-	// 启动工作 goroutine
-	for i := 0; i < s.thread; i++ {
-		s.wg.Add(1)
+// dispatch 启动 Thread 个 worker 并发执行 work，所有 worker 结束后关闭结果 channel
+func (s *Scanner) dispatch(work func(results chan<- Result)) <-chan Result {
+	results := make(chan Result)
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.opts.Thread; i++ {
+		wg.Add(1)
 		go func() {
-			defer s.wg.Done()
-			s.scan()
+			defer wg.Done()
+			work(results)
 		}()
 	}
 
-	// 等待所有 goroutine 完成
-	s.wg.Wait()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
 
-	// 输出扫描统计（非静默模式且非 JSON 模式）
-	if !s.silent && !s.jsonOutput {
-		color.RGBStyleFromString("244,211,49").Printf("\n[+] Scanned: %d, Matched: %d\n", len(s.allResults), len(s.hitResults))
+// sendResult 发送一条结果，ctx 取消时放弃发送并返回 false
+func sendResult(ctx context.Context, results chan<- Result, result Result) bool {
+	select {
+	case results <- result:
+		return true
+	case <-ctx.Done():
+		return false
 	}
+}
 
-	// 保存结果到文件
-	if s.output != "" {
-		saveResults(s.output, s.allResults)
+// analyzeOrigin 识别同一源的所有响应并合并结果
+// 结果中的 URL 为源地址，其余字段取自首页响应（没有首页时取第一个响应）
+//
+// 参数：
+//   - ctx: 上下文
+//   - group: 同一源的响应列表
+//   - get: 资源获取函数
+//
+// 返回：
+//   - Result: 合并后的识别结果
+func (s *Scanner) analyzeOrigin(ctx context.Context, group []*Response, get resourceGetter) Result {
+	var matched []string
+	main := group[0]
+	for _, resp := range group {
+		isMain := isRootPath(resp.URL)
+		if isMain && !isRootPath(main.URL) {
+			main = resp
+		}
+		matched = appendUnique(matched, s.match(ctx, resp, isMain, get)...)
 	}
+
+	result := newResult(main, matched)
+	result.URL = responseOrigin(main.URL)
+	return result
 }
 
 // detectFingerprints 使用 fingers 引擎检测指纹
@@ -209,248 +333,391 @@ func (s *Scanner) Run() {
 //
 // 返回：
 //   - []string: 检测到的框架名称列表
-func (s *Scanner) detectFingerprints(rawContent []byte) []string {
-	var allNames []string
-	seen := make(map[string]bool)
-
-	// 使用默认引擎检测
-	if s.engine != nil {
-		frameworks, err := s.engine.DetectContent(rawContent)
-		if err == nil {
-			for _, name := range frameworks.GetNames() {
-				if !seen[name] {
-					seen[name] = true
-					allNames = append(allNames, name)
-				}
-			}
-		}
+//   - []string: 命中的规则，形如 "来源引擎/名称"，用于调试日志
+func (rs *ruleSet) detectFingerprints(rawContent []byte) ([]string, []string) {
+	frameworks, err := rs.engine.DetectContent(rawContent)
+	if err != nil {
+		return nil, nil
 	}
 
-	// 使用自定义引擎检测
-	if s.customEngine != nil {
-		frameworks, err := s.customEngine.DetectContent(rawContent)
-		if err == nil {
-			for _, name := range frameworks.GetNames() {
-				if !seen[name] {
-					seen[name] = true
-					allNames = append(allNames, name)
-				}
-			}
+	var ids []string
+	for _, frame := range frameworks {
+		var froms []string
+		for from := range frame.Froms {
+			froms = append(froms, from.String())
+		}
+		sort.Strings(froms)
+		for _, from := range froms {
+			ids = append(ids, from+"/"+frame.Name)
 		}
 	}
+	sort.Strings(ids)
+	return appendUnique(nil, frameworks.GetNames()...), ids
+}
 
-	return allNames
+// matchFavicon 使用 fingers 引擎检测 favicon 指纹
+//
+// 参数：
+//   - faviconContent: favicon 文件内容
+//
+// 返回：
+//   - []string: 检测到的框架名称列表
+func (rs *ruleSet) matchFavicon(faviconContent []byte) []string {
+	return appendUnique(nil, rs.engine.MatchFavicon(faviconContent).GetNames()...)
 }
 
-// detectFavicon 使用 fingers 引擎检测 favicon 指纹
-// 主动获取 favicon 文件并进行 hash 匹配
+// matchFaviconHash 按 MMH3 hash 检测 favicon 指纹，用于只知道 hash 而没有图标内容的情况
 //
 // 参数：
-//   - body: HTML 响应体，用于提取 favicon URL
-//   - baseURL: 当前页面 URL
+//   - faviconHash: favicon 的 MMH3 hash
 //
 // 返回：
 //   - []string: 检测到的框架名称列表
-func (s *Scanner) detectFavicon(body, baseURL string) []string {
-	// 提取 favicon URL
-	faviconURL := extractFaviconURL(body, baseURL)
-	if faviconURL == "" {
+func (rs *ruleSet) matchFaviconHash(faviconHash string) []string {
+	favicons := rs.engine.Favicon()
+	if favicons == nil {
 		return nil
 	}
-
-	// 获取 favicon 内容
-	faviconContent, err := fetchFavicon(faviconURL, s.proxy)
-	if err != nil || len(faviconContent) == 0 {
-		return nil
+	if frame := favicons.HashMatch("", faviconHash); frame != nil {
+		return []string{frame.Name}
 	}
+	return nil
+}
 
-	var allNames []string
-	seen := make(map[string]bool)
-
-	// 使用默认引擎的 favicon 检测
-	if s.engine != nil {
-		frameworks := s.engine.MatchFavicon(faviconContent)
-		for _, name := range frameworks.GetNames() {
-			if !seen[name] {
-				seen[name] = true
-				allNames = append(allNames, name)
-			}
-		}
-	}
+// scanState 一次扫描中所有 worker 共享的状态
+type scanState struct {
+	queue   *Queue          // 任务队列
+	probed  *originSet      // 已主动探测过的源
+	vhosts  *vhostBaselines // 虚拟主机识别的基准响应
+	targets *originSet      // 已加入扫描的源，用于证书 SAN 目标去重
 
-	// 使用自定义引擎的 favicon 检测
-	if s.customEngine != nil {
-		frameworks := s.customEngine.MatchFavicon(faviconContent)
-		for _, name := range frameworks.GetNames() {
-			if !seen[name] {
-				seen[name] = true
-				allNames = append(allNames, name)
-			}
-		}
-	}
+	vhostTransports *vhostTransports // 虚拟主机请求的传输层
 
-	return allNames
+	mu     sync.Mutex
+	cond   *sync.Cond // 队列中有新任务或任务全部完成时通知等待的 worker
+	active int        // 正在处理的任务数，处理中的任务可能继续产生新任务
 }
 
-// getFaviconHash 获取 favicon 的 MMH3 hash
-func (s *Scanner) getFaviconHash(body, baseURL string) string {
-	faviconURL := extractFaviconURL(body, baseURL)
-	if faviconURL == "" {
-		return ""
+// newScanState 创建扫描状态
+func newScanState(queue *Queue) *scanState {
+	st := &scanState{
+		queue:   queue,
+		probed:  &originSet{seen: make(map[string]bool)},
+		vhosts:  &vhostBaselines{baselines: make(map[string][]*Response)},
+		targets: &originSet{seen: make(map[string]bool)},
+
+		vhostTransports: &vhostTransports{transports: make(map[string]http.RoundTripper)},
 	}
+	st.cond = sync.NewCond(&st.mu)
+	return st
+}
+
+// push 添加新任务并唤醒等待的 worker
+func (st *scanState) push(task []string) {
+	st.queue.Push(task)
+	st.cond.Broadcast()
+}
 
-	faviconContent, err := fetchFavicon(faviconURL, s.proxy)
-	if err != nil || len(faviconContent) == 0 {
-		return ""
+// next 取出下一个任务
+// 队列为空但仍有任务在处理时等待，避免处理中的任务产生的 JS 跳转、虚拟主机任务只能由一个 worker 执行；
+// 队列为空且没有正在处理的任务，或 ctx 已取消时返回 nil
+func (st *scanState) next(ctx context.Context) []string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for ctx.Err() == nil {
+		if task, ok := st.queue.Pop().([]string); ok {
+			st.active++
+			return task
+		}
+		if st.active == 0 {
+			return nil
+		}
+		st.cond.Wait()
 	}
+	return nil
+}
 
-	return calcFaviconHash(faviconContent)
+// done 记录一个任务处理完成
+func (st *scanState) done() {
+	st.mu.Lock()
+	st.active--
+	st.mu.Unlock()
+	st.cond.Broadcast()
 }
 
 // scan 执行扫描任务
-// 从队列中获取 URL，发送请求，进行指纹检测，输出结果
-func (s *Scanner) scan() {
+// 从队列中获取 URL，发送请求，进行指纹检测，发送结果
+//
+// 参数：
+//   - ctx: 上下文，取消后停止扫描
+//   - state: 扫描中共享的状态
+//   - results: 结果 channel
+func (s *Scanner) scan(ctx context.Context, state *scanState, results chan<- Result) {
+	// 没有剩余任务或扫描取消后不再发送虚拟主机请求
+	defer state.vhostTransports.closeIdle()
 	for {
-		// 从队列获取任务
-		item := s.queue.Pop()
-		if item == nil {
+		task := state.next(ctx)
+		if task == nil {
 			return
 		}
-
-		task, ok := item.([]string)
+		ok := s.scanTask(ctx, task, state, results)
+		state.done()
 		if !ok {
-			continue
+			return
 		}
+	}
+}
 
-		// 发送 HTTP 请求
-		resp, err := fetch(task, s.proxy)
-		if err != nil {
-			// 如果 HTTPS 失败，尝试 HTTP
-			task[0] = strings.ReplaceAll(task[0], "https://", "http://")
-			resp, err = fetch(task, s.proxy)
-			if err != nil {
-				continue
-			}
+// scanTask 处理单个任务：发送请求，进行指纹检测，发送结果
+//
+// 参数：
+//   - ctx: 上下文，取消后停止扫描
+//   - task: 任务
+//   - state: 扫描中共享的状态
+//   - results: 结果 channel
+//
+// 返回：
+//   - bool: 是否继续扫描，ctx 已取消时为 false
+func (s *Scanner) scanTask(ctx context.Context, task []string, state *scanState, results chan<- Result) bool {
+	s.opts.Progress.taskStarted()
+
+	// 虚拟主机任务：与基准响应不同时才输出结果
+	if task[1] == taskVHost {
+		result, distinct, err := s.scanVHost(ctx, task, state)
+		switch {
+		case ctx.Err() != nil:
+			s.opts.Progress.taskAborted()
+			return false
+		case err != nil:
+			s.opts.Progress.taskFailed()
+			return true
 		}
+		s.opts.Progress.taskDone(result)
+		return !distinct || s.excluded(result) || sendResult(ctx, results, result)
+	}
 
-		// 处理 JS 跳转
-		// 将 JS 跳转的 URL 添加到队列继续扫描
-		for _, jsURL := range resp.JsURLs {
-			if jsURL != "" {
-				s.queue.Push([]string{jsURL, "1"})
-			}
+	// 发送 HTTP 请求
+	resp, err := s.fetch(ctx, task)
+	if err != nil {
+		if ctx.Err() != nil {
+			s.opts.Progress.taskAborted()
+			return false
 		}
+		// 如果 HTTPS 失败，尝试 HTTP
+		task[0] = strings.ReplaceAll(task[0], "https://", "http://")
+		resp, err = s.fetch(ctx, task)
+		if err != nil {
+			s.opts.Progress.taskFailed()
+			return true
+		}
+	}
+	resp.IPs = s.resolveIPs(ctx, resp.URL)
+	if s.opts.CDN {
+		resp.CDN = s.detectCDN(ctx, resp)
+	}
 
-		// 使用 fingers 引擎进行指纹检测
-		matched := s.detectFingerprints(resp.RawContent)
-
-		// 使用 ARL 引擎进行指纹检测（如果启用）
-		if s.arlEngine != nil {
-			// 计算 favicon hash（如果需要）
-			faviconHash := ""
-			if task[1] == "0" {
-				faviconHash = s.getFaviconHash(resp.Body, resp.URL)
-			}
-			// ARL 匹配
-			arlMatched := s.arlEngine.Match(resp.Body, resp.Header, resp.Title, faviconHash)
-			for _, m := range arlMatched {
-				// 避免重复
-				exists := false
-				for _, existing := range matched {
-					if existing == m {
-						exists = true
-						break
-					}
-				}
-				if !exists {
-					matched = append(matched, m)
-				}
-			}
+	// 处理 JS 跳转
+	// 将 JS 跳转的 URL 添加到队列继续扫描
+	for _, jsURL := range resp.JsURLs {
+		if jsURL != "" {
+			s.opts.Logger.Debugf("%s JS 跳转: %s", resp.URL, jsURL)
+			s.opts.Progress.addTotal(1)
+			state.push([]string{jsURL, "1"})
 		}
+	}
 
-		// 主动获取 favicon 进行指纹检测（仅对主页面，且未使用 ARL）
-		if task[1] == "0" && s.arlEngine == nil {
-			faviconMatches := s.detectFavicon(resp.Body, resp.URL)
-			for _, faviconMatch := range faviconMatches {
-				// 检查是否已存在，避免重复
-				exists := false
-				for _, m := range matched {
-					if m == faviconMatch {
-						exists = true
-						break
-					}
-				}
-				if !exists {
-					matched = append(matched, faviconMatch)
-				}
+	// 证书 SAN 中的域名作为新目标，只从用户指定的目标扩展一层
+	if task[1] == "0" && s.opts.CertTargets {
+		for _, target := range sanTargets(resp.URL, resp.TLS) {
+			if state.targets.add(target) {
+				s.opts.Logger.Debugf("%s 证书 SAN 目标: %s", resp.URL, target)
+				s.opts.Progress.addTotal(1)
+				state.push([]string{target, taskCert})
 			}
 		}
+	}
 
-		// 构建扫描结果
-		result := Result{
-			URL:        resp.URL,
-			CMS:        strings.Join(matched, ","),
-			Server:     resp.Server,
-			StatusCode: resp.StatusCode,
-			Length:     resp.Length,
-			Title:      resp.Title,
-		}
+	// 指纹识别，主页面开启主动探测时合并探测路径上的结果
+	isMain := task[1] == "0" || task[1] == taskCert
+	if isMain && s.opts.JARM {
+		resp.JARM = s.jarm(ctx, resp.URL)
+	}
+	if isMain && s.opts.Protocols {
+		resp.Protocol = s.detectProtocol(ctx, resp)
+	}
+	if isMain && s.opts.WAF {
+		resp.WAF = s.detectWAF(ctx, resp)
+	}
+	matched := s.match(ctx, resp, isMain, s.getResource)
+	if isMain && s.probing() && state.probed.add(responseOrigin(resp.URL)) {
+		matched = appendUnique(matched, s.probe(ctx, resp)...)
+	}
+	if isMain && len(s.opts.VHosts) > 0 && isIPTarget(resp.URL) {
+		s.queueVHosts(ctx, resp, state)
+	}
 
-		// 保存结果（线程安全）
-		s.mu.Lock()
-		s.allResults = append(s.allResults, result)
-		if result.CMS != "" {
-			s.hitResults = append(s.hitResults, result)
-		}
-		s.mu.Unlock()
+	// 发送结果
+	result := newResult(resp, matched)
+	s.opts.Progress.taskDone(result)
+	if s.excluded(result) {
+		return true
+	}
+	return sendResult(ctx, results, result)
+}
 
-		// 输出结果
-		s.printResult(result)
+// excluded 判断结果是否因经过 CDN 或有 WAF 而不输出
+// 只有通用缓存响应头的 CDN 结果（Weak）可能来自源站自己的缓存，不排除
+func (s *Scanner) excluded(result Result) bool {
+	switch {
+	case s.opts.ExcludeCDN && result.CDN != nil && !result.CDN.Weak:
+		s.opts.Logger.Debugf("%s 经过 CDN (%s)，不输出", result.URL, result.CDN.Name)
+		return true
+	case s.opts.ExcludeWAF && result.WAF != nil:
+		s.opts.Logger.Debugf("%s 有 WAF (%s)，不输出", result.URL, result.WAF.Name)
+		return true
 	}
+	return false
 }
 
-// printResult 输出扫描结果
-// 根据模式选择不同的输出格式
+// fetch 发送请求并记录调试日志
+func (s *Scanner) fetch(ctx context.Context, task []string) (*Response, error) {
+	start := time.Now()
+	method := s.opts.Request.method()
+	resp, err := fetch(ctx, s.client, s.opts.Request, task)
+	if err != nil {
+		s.opts.Logger.Debugf("%s %s 失败: %v (%s)", method, task[0], err, time.Since(start).Round(time.Millisecond))
+		return nil, err
+	}
+	s.opts.Logger.Debugf("%s %s -> %d, %d 字节 (%s)", method, task[0], resp.StatusCode, resp.Length, time.Since(start).Round(time.Millisecond))
+	return resp, nil
+}
+
+// analyze 对单个响应进行指纹识别并构建结果
 //
 // 参数：
-//   - result: 扫描结果
-func (s *Scanner) printResult(result Result) {
-	// JSON 输出模式
-	if s.jsonOutput {
-		data, _ := json.Marshal(result)
-		fmt.Println(string(data))
-		return
-	}
-
-	// 静默模式：只输出命中指纹的结果
-	if s.silent {
-		if result.CMS != "" {
-			fmt.Printf("%s [%s]\n", result.URL, result.CMS)
-		}
-		return
+//   - ctx: 上下文
+//   - resp: 解析后的响应
+//   - isMain: 是否为主页面，只有主页面才获取 favicon
+//   - get: 资源获取函数
+//
+// 返回：
+//   - Result: 识别结果
+func (s *Scanner) analyze(ctx context.Context, resp *Response, isMain bool, get resourceGetter) Result {
+	return newResult(resp, s.match(ctx, resp, isMain, get))
+}
+
+// match 对单个响应进行指纹识别
+// 获取 favicon 后依次执行 fingers 引擎匹配、ARL 匹配、JARM 匹配和 favicon 匹配，在线扫描和离线匹配共用
+// ctx 取消后 favicon 请求立即中止，ARL 匹配提前结束，返回已命中的部分结果
+//
+// 参数：
+//   - ctx: 上下文
+//   - resp: 解析后的响应
+//   - isMain: 是否为主页面，只有主页面才获取 favicon
+//   - get: 资源获取函数，在线扫描时发送请求，离线匹配时从导入的响应中查找
+//
+// 返回：
+//   - []string: 命中的指纹名称列表
+func (s *Scanner) match(ctx context.Context, resp *Response, isMain bool, get resourceGetter) []string {
+	// 主动获取 favicon（仅对主页面）
+	// 按 <link> 图标、manifest 图标、/favicon.ico 的顺序尝试，fingers 和 ARL 共用同一份内容
+	var faviconContent []byte
+	var faviconHash string
+	if isMain {
+		faviconContent = fetchFaviconContent(ctx, resp.Body, resp.URL, get)
+	}
+	if len(faviconContent) > 0 {
+		faviconHash = calcFaviconHash(faviconContent)
 	}
 
-	// 正常模式：httpx 风格输出
-	var parts []string
-	parts = append(parts, result.URL)
-	parts = append(parts, fmt.Sprintf("[%d]", result.StatusCode))
-	parts = append(parts, fmt.Sprintf("[%d]", result.Length))
-	if result.Server != "" {
-		parts = append(parts, fmt.Sprintf("[%s]", result.Server))
+	return s.matchResponse(ctx, resp, faviconContent, faviconHash)
+}
+
+// matchResponse 使用已获取的 favicon 对单个响应进行指纹识别，不发送任何请求
+// 开始时取一次当前的指纹引擎，识别过程中指纹被重新加载也不影响本次识别
+//
+// 参数：
+//   - ctx: 上下文
+//   - resp: 解析后的响应
+//   - faviconContent: favicon 内容，为空时只按 faviconHash 匹配
+//   - faviconHash: favicon 的 MMH3 hash，为空表示没有 favicon
+//
+// 返回：
+//   - []string: 命中的指纹名称列表
+func (s *Scanner) matchResponse(ctx context.Context, resp *Response, faviconContent []byte, faviconHash string) []string {
+	rs := s.rules.load()
+
+	// 使用 fingers 引擎进行指纹检测
+	matched, ids := rs.detectFingerprints(resp.RawContent)
+
+	// 使用 ARL 引擎进行指纹检测（如果启用）
+	if rs.arlEngine != nil {
+		names := rs.arlEngine.Match(ctx, resp.Body, resp.Header, resp.Title, faviconHash, resp.TLS.certText())
+		matched = appendUnique(matched, names...)
+		ids = appendRuleIDs(ids, "arl", names)
 	}
-	if result.Title != "" {
-		parts = append(parts, fmt.Sprintf("[%s]", result.Title))
+
+	// JARM 指纹检测
+	if names := rs.jarmRules.match(resp.JARM); len(names) > 0 {
+		matched = appendUnique(matched, names...)
+		ids = appendRuleIDs(ids, "jarm", names)
 	}
-	if result.CMS != "" {
-		parts = append(parts, fmt.Sprintf("[%s]", result.CMS))
+
+	// favicon 指纹检测
+	var names []string
+	if len(faviconContent) > 0 {
+		names = rs.matchFavicon(faviconContent)
+	} else if faviconHash != "" {
+		names = rs.matchFaviconHash(faviconHash)
 	}
+	matched = appendUnique(matched, names...)
+	ids = appendRuleIDs(ids, "ico", names)
 
-	line := strings.Join(parts, " ")
+	if len(ids) > 0 {
+		s.opts.Logger.Debugf("%s 命中规则: %s", resp.URL, strings.Join(ids, ", "))
+	}
+	return matched
+}
+
+// appendRuleIDs 追加 "来源/名称" 形式的命中规则
+func appendRuleIDs(ids []string, from string, names []string) []string {
+	for _, name := range names {
+		ids = append(ids, from+"/"+name)
+	}
+	return ids
+}
+
+// appendUnique 追加不重复的指纹名称
+func appendUnique(matched []string, names ...string) []string {
+	for _, name := range names {
+		exists := false
+		for _, m := range matched {
+			if m == name {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			matched = append(matched, name)
+		}
+	}
+	return matched
+}
 
-	// 命中指纹的结果用红色高亮显示
-	if result.CMS != "" {
-		color.RGBStyleFromString("237,64,35").Println(line)
-	} else {
-		fmt.Println(line)
+// newResult 根据响应和命中的指纹构建扫描结果
+func newResult(resp *Response, matched []string) Result {
+	return Result{
+		URL:        resp.URL,
+		CMS:        strings.Join(matched, ","),
+		Server:     resp.Server,
+		StatusCode: resp.StatusCode,
+		Length:     resp.Length,
+		Title:      resp.Title,
+		TLS:        resp.TLS,
+		IPs:        resp.IPs,
+		JARM:       resp.JARM,
+		CDN:        resp.CDN,
+		WAF:        resp.WAF,
+		Protocol:   resp.Protocol,
 	}
 }
@@ -0,0 +1,789 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件实现 HTTP API 服务：
+// 1. 通过 REST API 创建、查询和取消扫描任务
+// 2. 以 NDJSON 或 SSE 的形式流式返回扫描结果
+// 3. 所有任务共用一个已初始化的指纹引擎，任务在有界队列中排队执行
+// 4. 对单个响应同步进行指纹识别
+// 5. 查询和重新加载指纹
+// 6. 可选的访问令牌认证，已结束的任务按保留时间和结果总数上限自动删除
+package pkg
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxFingerprintBody 单个响应识别接口的请求体大小上限
+const maxFingerprintBody = 32 << 20
+
+// API 服务默认配置
+const (
+	DefaultJobTTL     = time.Hour // 已结束任务的默认保留时间
+	DefaultMaxResults = 100000    // 所有任务保存的结果总数默认上限
+)
+
+// 任务状态
+const (
+	JobQueued    = "queued"    // 排队中
+	JobRunning   = "running"   // 扫描中
+	JobDone      = "done"      // 已完成
+	JobCancelled = "cancelled" // 已取消
+)
+
+// ServerOptions API 服务配置
+type ServerOptions struct {
+	MaxJobs   int           // 同时执行的任务数，默认 1
+	QueueSize int           // 排队任务数上限，队列满时拒绝新任务，默认 100
+	MaxTime   time.Duration // 任务未指定 max_time 时的默认扫描时长上限，0 为不限制
+
+	Token         string        // 访问令牌，不为空时所有请求需携带 Authorization: Bearer <token> 或 ?token=<token>
+	JobTTL        time.Duration // 已结束任务的保留时间，超过后自动删除，默认 DefaultJobTTL
+	MaxResults    int           // 所有任务保存的结果总数上限，超过时先删除最早结束的任务，默认 DefaultMaxResults
+	AllowJobProxy bool          // 是否允许任务通过 proxy 参数指定代理，默认不允许（任务只能使用服务的代理池）
+}
+
+// JobOptions 单个任务的扫描参数，与命令行参数对应，未指定的参数使用服务的默认值
+type JobOptions struct {
+	Thread  int    `json:"thread,omitempty"`   // 并发线程数（-t）
+	Timeout int    `json:"timeout,omitempty"`  // 请求超时时间，秒（--timeout）
+	Proxy   string `json:"proxy,omitempty"`    // 代理地址（-p），指定后不使用服务的代理池，需要服务开启 AllowJobProxy
+	MaxTime int    `json:"max_time,omitempty"` // 扫描总时长上限，秒（--max-time）
+
+	Probe      bool     `json:"probe,omitempty"`       // 主动探测规则中声明的路径（--probe）
+	ProbePaths []string `json:"probe_paths,omitempty"` // 额外的主动探测路径（--probe-paths）
+	ProbeRate  int      `json:"probe_rate,omitempty"`  // 探测请求每秒上限（--probe-rate）
+
+	ProbeSources []string `json:"probe_sources,omitempty"` // 规则路径的来源（--probe-sources）
+	ProbeMax     int      `json:"probe_max,omitempty"`     // 每个源最多探测的路径数（--probe-max）
+	ProbeThreads int      `json:"probe_threads,omitempty"` // 每个源的探测并发数（--probe-threads）
+
+	VHosts      []string `json:"vhosts,omitempty"`       // 虚拟主机识别的候选域名（--vhost），与服务的默认候选域名合并
+	CertTargets bool     `json:"cert_targets,omitempty"` // 将证书 SAN 中的域名作为新目标扫描（--cert-targets）
+	JARM        bool     `json:"jarm,omitempty"`         // 对 HTTPS 目标进行 JARM 探测（--jarm）
+	Protocols   bool     `json:"protocols,omitempty"`    // 检测目标支持的 HTTP 协议（--protocols）
+
+	CDN        bool `json:"cdn,omitempty"`         // 检测目标是否经过 CDN（--cdn）
+	WAF        bool `json:"waf,omitempty"`         // 检测目标是否有 WAF（--waf）
+	ExcludeCDN bool `json:"exclude_cdn,omitempty"` // 不输出经过 CDN 的结果（--exclude-cdn）
+	ExcludeWAF bool `json:"exclude_waf,omitempty"` // 不输出有 WAF 的结果（--exclude-waf）
+
+	Method    string            `json:"method,omitempty"`     // 请求方法（-X）
+	Headers   map[string]string `json:"headers,omitempty"`    // 请求头（-H），覆盖服务的同名请求头
+	Cookie    string            `json:"cookie,omitempty"`     // 请求 Cookie（--cookie）
+	Data      string            `json:"data,omitempty"`       // 请求体（-d），未指定请求方法时使用 POST
+	UserAgent string            `json:"user_agent,omitempty"` // 固定的 User-Agent（--ua）
+	NoShiro   bool              `json:"no_shiro,omitempty"`   // 不携带 Shiro 检测 Cookie（--no-shiro）
+	BasicAuth string            `json:"basic_auth,omitempty"` // HTTP Basic 认证（--basic-auth），任务信息中不返回
+	Bearer    string            `json:"bearer,omitempty"`     // Bearer Token（--bearer），指定后不使用服务的 Basic 认证，任务信息中不返回
+}
+
+// requestConfig 在服务的请求配置上覆盖任务指定的请求参数，覆盖方式与命令行参数覆盖 --request-config 一致
+//
+// 参数：
+//   - base: 服务的请求配置，可为 nil
+//
+// 返回：
+//   - *RequestConfig: 任务使用的请求配置，任务没有指定请求参数时为 base
+//   - error: 请求方法、请求头或认证信息无效
+func (o JobOptions) requestConfig(base *RequestConfig) (*RequestConfig, error) {
+	if o.Method == "" && len(o.Headers) == 0 && o.Cookie == "" && o.Data == "" && o.UserAgent == "" &&
+		!o.NoShiro && o.BasicAuth == "" && o.Bearer == "" {
+		return base, nil
+	}
+
+	config := &RequestConfig{}
+	if base != nil {
+		*config = *base
+	}
+	config.Headers = make(map[string]string, len(config.Headers)+len(o.Headers))
+	if base != nil {
+		for name, value := range base.Headers {
+			config.Headers[name] = value
+		}
+	}
+	for name, value := range o.Headers {
+		for existing := range config.Headers {
+			if strings.EqualFold(existing, name) {
+				delete(config.Headers, existing)
+			}
+		}
+		config.Headers[name] = value
+	}
+
+	if o.Method != "" {
+		config.Method = o.Method
+	}
+	if o.Cookie != "" {
+		config.Cookies = o.Cookie
+	}
+	if o.Data != "" {
+		config.Body = o.Data
+		if config.Method == "" {
+			config.Method = "POST"
+		}
+	}
+	if o.UserAgent != "" {
+		config.UserAgents = []string{o.UserAgent}
+	}
+	if o.NoShiro {
+		config.NoShiroCookie = true
+	}
+	if o.BasicAuth != "" {
+		config.BasicAuth = o.BasicAuth
+	} else if o.Bearer != "" {
+		config.BasicAuth = ""
+	}
+	if o.Bearer != "" {
+		config.BearerToken = o.Bearer
+	}
+	return config, config.Validate()
+}
+
+// JobInfo 任务状态，作为 API 的响应
+type JobInfo struct {
+	ID         string           `json:"id"`                    // 任务 ID
+	Status     string           `json:"status"`                // 任务状态
+	Targets    int              `json:"targets"`               // 目标数量
+	Options    JobOptions       `json:"options"`               // 扫描参数
+	Progress   ProgressSnapshot `json:"progress"`              // 扫描进度
+	TimedOut   bool             `json:"timed_out,omitempty"`   // 是否因达到 max_time 提前结束
+	Dropped    int              `json:"dropped,omitempty"`     // 因达到服务的结果总数上限而未保存的结果数
+	CreatedAt  time.Time        `json:"created_at"`            // 创建时间
+	StartedAt  *time.Time       `json:"started_at,omitempty"`  // 开始时间
+	FinishedAt *time.Time       `json:"finished_at,omitempty"` // 结束时间
+}
+
+// job 扫描任务
+type job struct {
+	id       string
+	targets  []string
+	opts     JobOptions
+	progress *Progress
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	mu         sync.Mutex
+	status     string
+	timedOut   bool
+	results    []Result
+	dropped    int           // 未保存的结果数
+	changed    chan struct{} // 状态或结果变化时关闭并替换，用于通知流式读取
+	createdAt  time.Time
+	startedAt  *time.Time
+	finishedAt *time.Time
+}
+
+// Server 扫描任务 API 服务，实现 http.Handler
+//
+// 接口：
+//   - POST   /api/jobs              创建任务，请求体为 {"targets": [...], "thread": 50, ...}
+//   - GET    /api/jobs              列出所有任务
+//   - GET    /api/jobs/{id}         查询任务状态和进度
+//   - GET    /api/jobs/{id}/results 流式返回结果（NDJSON，Accept: text/event-stream 或 ?format=sse 时为 SSE）
+//   - DELETE /api/jobs/{id}         取消任务，已结束的任务则删除
+//   - POST   /api/fingerprint       同步识别单个响应，请求体为 FingerprintRequest 或原始 HTTP 响应
+//   - GET    /api/rules             查询已加载的规则数量
+//   - POST   /api/rules/reload      重新加载指纹
+type Server struct {
+	scanner *Scanner
+	opts    ServerOptions
+	queue   chan *job
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	order  []string
+	stored int // 所有任务保存的结果数
+	nextID int64
+	closed bool
+}
+
+// NewServer 创建 API 服务并启动任务执行 goroutine
+//
+// 参数：
+//   - scanner: 已初始化的扫描器，所有任务共用其指纹引擎，线程数、超时和代理作为任务的默认值
+//   - opts: 服务配置
+//
+// 返回：
+//   - *Server: API 服务实例
+func NewServer(scanner *Scanner, opts ServerOptions) *Server {
+	if opts.MaxJobs <= 0 {
+		opts.MaxJobs = 1
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 100
+	}
+	if opts.JobTTL <= 0 {
+		opts.JobTTL = DefaultJobTTL
+	}
+	if opts.MaxResults <= 0 {
+		opts.MaxResults = DefaultMaxResults
+	}
+
+	s := &Server{
+		scanner: scanner,
+		opts:    opts,
+		queue:   make(chan *job, opts.QueueSize),
+		jobs:    make(map[string]*job),
+	}
+	for i := 0; i < opts.MaxJobs; i++ {
+		go s.worker()
+	}
+	return s
+}
+
+// Close 取消所有任务并停止接收新任务
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	for _, j := range s.jobs {
+		j.cancel()
+	}
+	close(s.queue)
+}
+
+// ServeHTTP 处理 API 请求
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "未提供或错误的访问令牌")
+		return
+	}
+	s.evict()
+
+	path := strings.Trim(r.URL.Path, "/")
+	if path == "api/fingerprint" {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "不支持的请求方法")
+			return
+		}
+		s.fingerprint(w, r)
+		return
+	}
+	if path == "api/rules" || path == "api/rules/reload" {
+		s.rules(w, r, path == "api/rules/reload")
+		return
+	}
+	if path != "api/jobs" && !strings.HasPrefix(path, "api/jobs/") {
+		writeError(w, http.StatusNotFound, "接口不存在")
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(path, "api/jobs"), "/")[1:]
+
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodPost:
+		s.createJob(w, r)
+	case len(parts) == 0 && r.Method == http.MethodGet:
+		s.listJobs(w)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		if j := s.lookup(w, parts[0]); j != nil {
+			writeJSON(w, http.StatusOK, j.info())
+		}
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		if j := s.lookup(w, parts[0]); j != nil {
+			s.deleteJob(w, j)
+		}
+	case len(parts) == 2 && parts[1] == "results" && r.Method == http.MethodGet:
+		if j := s.lookup(w, parts[0]); j != nil {
+			s.streamResults(w, r, j)
+		}
+	case len(parts) <= 2:
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方法")
+	default:
+		writeError(w, http.StatusNotFound, "接口不存在")
+	}
+}
+
+// createJob 创建任务并加入队列，队列已满时返回 503
+func (s *Server) createJob(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Targets []string `json:"targets"`
+		JobOptions
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("请求体解析失败: %v", err))
+		return
+	}
+
+	var targets []string
+	for _, target := range req.Targets {
+		if strings.TrimSpace(target) != "" {
+			targets = append(targets, NormalizeTarget(target))
+		}
+	}
+	if len(targets) == 0 {
+		writeError(w, http.StatusBadRequest, "targets 不能为空")
+		return
+	}
+	if req.Thread < 0 || req.Timeout < 0 || req.MaxTime < 0 || req.ProbeRate < 0 || req.ProbeMax < 0 || req.ProbeThreads < 0 {
+		writeError(w, http.StatusBadRequest, "thread、timeout、max_time、probe_rate、probe_max、probe_threads 不能为负数")
+		return
+	}
+	sources, err := ParseProbeSources(req.ProbeSources)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.ProbeSources = sources
+	if req.Proxy != "" {
+		if !s.opts.AllowJobProxy {
+			writeError(w, http.StatusForbidden, "服务不允许任务指定代理")
+			return
+		}
+		if _, err := ParseProxy(req.Proxy); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	for i, path := range req.ProbePaths {
+		if !strings.HasPrefix(path, "/") {
+			req.ProbePaths[i] = "/" + path
+		}
+	}
+	req.VHosts = NormalizeVHosts(req.VHosts)
+	if _, err := req.requestConfig(s.scanner.opts.Request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		targets:   targets,
+		opts:      req.JobOptions,
+		progress:  &Progress{},
+		ctx:       ctx,
+		cancel:    cancel,
+		status:    JobQueued,
+		changed:   make(chan struct{}),
+		createdAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		cancel()
+		writeError(w, http.StatusServiceUnavailable, "服务正在关闭")
+		return
+	}
+	s.nextID++
+	j.id = strconv.FormatInt(s.nextID, 10)
+	select {
+	case s.queue <- j:
+	default:
+		s.mu.Unlock()
+		cancel()
+		writeError(w, http.StatusServiceUnavailable, "任务队列已满，请稍后重试")
+		return
+	}
+	s.jobs[j.id] = j
+	s.order = append(s.order, j.id)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusAccepted, j.info())
+}
+
+// fingerprint 同步识别单个响应
+// Content-Type 为 JSON 时请求体为 FingerprintRequest，否则作为原始 HTTP 响应报文，URL 由 ?url= 指定
+func (s *Server) fingerprint(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxFingerprintBody))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("请求体读取失败: %v", err))
+		return
+	}
+
+	var req FingerprintRequest
+	if strings.Contains(r.Header.Get("Content-Type"), "json") {
+		if err := json.Unmarshal(data, &req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("请求体解析失败: %v", err))
+			return
+		}
+	} else {
+		req.URL = r.URL.Query().Get("url")
+		req.Raw = string(data)
+	}
+
+	result, err := s.scanner.Fingerprint(r.Context(), req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("响应解析失败: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// rules 查询或重新加载指纹
+// 重新加载失败时返回 500 和错误信息，服务继续使用原指纹
+func (s *Server) rules(w http.ResponseWriter, r *http.Request, reload bool) {
+	if !reload {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "不支持的请求方法")
+			return
+		}
+		writeJSON(w, http.StatusOK, s.scanner.RuleStats())
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方法")
+		return
+	}
+	stats, err := s.scanner.Reload()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{
+			"error": fmt.Sprintf("重新加载指纹失败，继续使用原指纹: %v", err),
+			"rules": stats,
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// listJobs 按创建顺序列出所有任务
+func (s *Server) listJobs(w http.ResponseWriter) {
+	s.mu.Lock()
+	infos := make([]JobInfo, 0, len(s.order))
+	for _, id := range s.order {
+		infos = append(infos, s.jobs[id].info())
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, infos)
+}
+
+// lookup 查找任务，不存在时返回 404
+func (s *Server) lookup(w http.ResponseWriter, id string) *job {
+	s.mu.Lock()
+	j := s.jobs[id]
+	s.mu.Unlock()
+	if j == nil {
+		writeError(w, http.StatusNotFound, "任务不存在")
+	}
+	return j
+}
+
+// deleteJob 取消排队中或扫描中的任务，删除已结束的任务
+func (s *Server) deleteJob(w http.ResponseWriter, j *job) {
+	j.mu.Lock()
+	finished := j.finishedAt != nil
+	j.mu.Unlock()
+
+	if !finished {
+		j.cancel()
+		// 排队中的任务不会再被执行，直接标记为已取消
+		j.finish(JobQueued, false)
+		writeJSON(w, http.StatusAccepted, j.info())
+		return
+	}
+
+	s.mu.Lock()
+	s.remove(j)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorized 检查请求携带的访问令牌，没有设置令牌时始终通过
+func (s *Server) authorized(r *http.Request) bool {
+	if s.opts.Token == "" {
+		return true
+	}
+	token := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Token)) == 1
+}
+
+// evict 删除超过保留时间的已结束任务
+func (s *Server) evict() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deadline := time.Now().Add(-s.opts.JobTTL)
+	for _, j := range s.finishedJobs() {
+		if j.finishedAt.Before(deadline) {
+			s.remove(j)
+		}
+	}
+}
+
+// store 保存任务的一条结果
+// 结果总数达到上限时按结束时间从早到晚删除已结束的任务，仍然没有空间时不保存该结果，只记录数量
+func (s *Server) store(j *job, result Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stored >= s.opts.MaxResults {
+		for _, f := range s.finishedJobs() {
+			s.remove(f)
+			if s.stored < s.opts.MaxResults {
+				break
+			}
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if s.stored >= s.opts.MaxResults {
+		j.dropped++
+		return
+	}
+	s.stored++
+	j.results = append(j.results, result)
+	j.notify()
+}
+
+// finishedJobs 按结束时间从早到晚返回已结束的任务，调用时需持有 s.mu
+func (s *Server) finishedJobs() []*job {
+	var finished []*job
+	for _, id := range s.order {
+		j := s.jobs[id]
+		j.mu.Lock()
+		if j.finishedAt != nil {
+			finished = append(finished, j)
+		}
+		j.mu.Unlock()
+	}
+	sort.SliceStable(finished, func(a, b int) bool {
+		return finished[a].finishedAt.Before(*finished[b].finishedAt)
+	})
+	return finished
+}
+
+// remove 删除任务，调用时需持有 s.mu
+func (s *Server) remove(j *job) {
+	if _, ok := s.jobs[j.id]; !ok {
+		return
+	}
+	j.mu.Lock()
+	s.stored -= len(j.results)
+	j.mu.Unlock()
+	delete(s.jobs, j.id)
+	for i, id := range s.order {
+		if id == j.id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// streamResults 流式返回任务结果
+// 先返回已有的结果，任务未结束时持续等待新结果，直到任务结束或客户端断开
+// SSE 模式下每条结果为一个 result 事件，任务结束时发送包含任务状态的 done 事件
+func (s *Server) streamResults(w http.ResponseWriter, r *http.Request, j *job) {
+	sse := r.URL.Query().Get("format") == "sse" || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	sent := 0
+	for {
+		j.mu.Lock()
+		results := j.results[sent:]
+		finished := j.finishedAt != nil
+		changed := j.changed
+		j.mu.Unlock()
+
+		for _, result := range results {
+			data, _ := json.Marshal(result)
+			if sse {
+				fmt.Fprintf(w, "event: result\ndata: %s\n\n", data)
+			} else {
+				fmt.Fprintf(w, "%s\n", data)
+			}
+		}
+		sent += len(results)
+
+		if finished {
+			if sse {
+				data, _ := json.Marshal(j.info())
+				fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// worker 依次执行队列中的任务
+func (s *Server) worker() {
+	for j := range s.queue {
+		s.run(j)
+	}
+}
+
+// run 执行单个任务
+// 未指定的扫描参数使用服务扫描器的配置，指纹引擎始终共用
+func (s *Server) run(j *job) {
+	if !j.start() {
+		return
+	}
+
+	base := s.scanner.opts
+	opts := Options{
+		Thread:       base.Thread,
+		Timeout:      base.Timeout,
+		Proxy:        base.Proxy,
+		Proxies:      base.Proxies,
+		Resolver:     base.Resolver,
+		Logger:       base.Logger,
+		Progress:     j.progress,
+		Probe:        base.Probe || j.opts.Probe,
+		ProbePaths:   append(append([]string(nil), base.ProbePaths...), j.opts.ProbePaths...),
+		ProbeRate:    base.ProbeRate,
+		ProbeSources: base.ProbeSources,
+		ProbeMax:     base.ProbeMax,
+		ProbeThreads: base.ProbeThreads,
+		VHosts:       NormalizeVHosts(append(append([]string(nil), base.VHosts...), j.opts.VHosts...)),
+		CertTargets:  base.CertTargets || j.opts.CertTargets,
+		JARM:         base.JARM || j.opts.JARM,
+		Protocols:    base.Protocols || j.opts.Protocols,
+		CDN:          base.CDN || j.opts.CDN,
+		CDNRanges:    base.CDNRanges,
+		WAF:          base.WAF || j.opts.WAF,
+		ExcludeCDN:   base.ExcludeCDN || j.opts.ExcludeCDN,
+		ExcludeWAF:   base.ExcludeWAF || j.opts.ExcludeWAF,
+	}
+	// 请求参数已在创建任务时检查
+	opts.Request, _ = j.opts.requestConfig(base.Request)
+	if j.opts.Thread > 0 {
+		opts.Thread = j.opts.Thread
+	}
+	if j.opts.Timeout > 0 {
+		opts.Timeout = time.Duration(j.opts.Timeout) * time.Second
+	}
+	if j.opts.Proxy != "" {
+		opts.Proxy, opts.Proxies = j.opts.Proxy, nil
+	}
+	if j.opts.ProbeRate > 0 {
+		opts.ProbeRate = j.opts.ProbeRate
+	}
+	if len(j.opts.ProbeSources) > 0 {
+		opts.ProbeSources = j.opts.ProbeSources
+	}
+	if j.opts.ProbeMax > 0 {
+		opts.ProbeMax = j.opts.ProbeMax
+	}
+	if j.opts.ProbeThreads > 0 {
+		opts.ProbeThreads = j.opts.ProbeThreads
+	}
+
+	ctx, cancel := j.ctx, context.CancelFunc(func() {})
+	maxTime := s.opts.MaxTime
+	if j.opts.MaxTime > 0 {
+		maxTime = time.Duration(j.opts.MaxTime) * time.Second
+	}
+	if maxTime > 0 {
+		ctx, cancel = context.WithTimeout(ctx, maxTime)
+	}
+	defer cancel()
+
+	base.Logger.Debugf("任务 %s 开始扫描: %d 个目标", j.id, len(j.targets))
+	for result := range s.scanner.WithOptions(opts).Scan(ctx, j.targets) {
+		s.store(j, result)
+	}
+	j.finish(JobRunning, ctx.Err() == context.DeadlineExceeded)
+
+	info := j.info()
+	base.Logger.Infof("任务 %s 已结束: %s, 完成 %d/%d, 命中 %d", j.id, info.Status, info.Progress.Done, info.Progress.Total, info.Progress.Hits)
+}
+
+// start 将排队中的任务标记为扫描中，任务已被取消时返回 false
+func (j *job) start() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.status != JobQueued || j.ctx.Err() != nil {
+		return false
+	}
+	now := time.Now()
+	j.status = JobRunning
+	j.startedAt = &now
+	j.notify()
+	return true
+}
+
+// finish 结束处于 from 状态的任务
+// 任务被取消时状态为 cancelled，否则为 done
+func (j *job) finish(from string, timedOut bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.status != from || j.finishedAt != nil {
+		return
+	}
+	now := time.Now()
+	j.status = JobDone
+	if j.ctx.Err() != nil {
+		j.status = JobCancelled
+	}
+	j.timedOut = timedOut
+	j.finishedAt = &now
+	j.notify()
+}
+
+// notify 通知等待中的流式读取，调用时需持有 j.mu
+func (j *job) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// info 返回任务当前状态
+func (j *job) info() JobInfo {
+	// 不返回认证信息
+	opts := j.opts
+	if opts.BasicAuth != "" {
+		opts.BasicAuth = "***"
+	}
+	if opts.Bearer != "" {
+		opts.Bearer = "***"
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return JobInfo{
+		ID:         j.id,
+		Status:     j.status,
+		Targets:    len(j.targets),
+		Options:    opts,
+		Progress:   j.progress.Snapshot(),
+		TimedOut:   j.timedOut,
+		Dropped:    j.dropped,
+		CreatedAt:  j.createdAt,
+		StartedAt:  j.startedAt,
+		FinishedAt: j.finishedAt,
+	}
+}
+
+// writeJSON 输出 JSON 响应
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError 输出错误响应
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
@@ -0,0 +1,161 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJobRequestConfig(t *testing.T) {
+	base := &RequestConfig{
+		Method:     "PUT",
+		Headers:    map[string]string{"X-Env": "prod", "X-Keep": "1"},
+		BasicAuth:  "admin:pass",
+		UserAgents: []string{"base-ua"},
+	}
+
+	tests := []struct {
+		name string
+		opts JobOptions
+		want RequestConfig
+	}{
+		{
+			name: "headers override case-insensitively",
+			opts: JobOptions{Headers: map[string]string{"x-env": "test"}, Cookie: "a=1"},
+			want: RequestConfig{
+				Method:     "PUT",
+				Headers:    map[string]string{"x-env": "test", "X-Keep": "1"},
+				Cookies:    "a=1",
+				BasicAuth:  "admin:pass",
+				UserAgents: []string{"base-ua"},
+			},
+		},
+		{
+			name: "bearer replaces base basic auth",
+			opts: JobOptions{Bearer: "tok", UserAgent: "job-ua", NoShiro: true, Method: "get"},
+			want: RequestConfig{
+				Method:        "GET",
+				Headers:       map[string]string{"X-Env": "prod", "X-Keep": "1"},
+				BearerToken:   "tok",
+				UserAgents:    []string{"job-ua"},
+				NoShiroCookie: true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.requestConfig(base)
+			if err != nil {
+				t.Fatalf("requestConfig: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+	if base.Headers["X-Env"] != "prod" {
+		t.Errorf("base config modified: %+v", base.Headers)
+	}
+
+	// 请求体在没有请求方法时使用 POST
+	got, err := JobOptions{Data: "a=1"}.requestConfig(nil)
+	if err != nil || got.Method != "POST" || got.Body != "a=1" {
+		t.Errorf("data: got %+v, %v", got, err)
+	}
+	// 没有请求参数时使用服务的配置
+	if got, _ := (JobOptions{Thread: 5}).requestConfig(base); got != base {
+		t.Errorf("no request options: got %p, want base %p", got, base)
+	}
+
+	for _, opts := range []JobOptions{
+		{Method: "GE T"},
+		{Headers: map[string]string{"Bad Name": "x"}},
+		{BasicAuth: "nocolon"},
+	} {
+		if _, err := opts.requestConfig(base); err == nil {
+			t.Errorf("requestConfig(%+v): want error", opts)
+		}
+	}
+}
+
+// TestServerJobRequestOptions 任务的请求参数覆盖服务的请求配置，任务信息中不返回认证信息
+func TestServerJobRequestOptions(t *testing.T) {
+	var mu sync.Mutex
+	var received []*http.Request
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, r)
+		mu.Unlock()
+		w.Write([]byte("<title>ok</title>"))
+	}))
+	defer target.Close()
+
+	scanner, err := New(Options{
+		Rules:   &CustomFingerConfig{NoDefault: true},
+		Request: &RequestConfig{Headers: map[string]string{"X-Env": "prod"}, BasicAuth: "admin:pass"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := NewServer(scanner, ServerOptions{})
+	defer api.Close()
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/jobs", "application/json",
+		strings.NewReader(`{"targets": ["`+target.URL+`"], "headers": {"x-env": "test"}, "bearer": "secret"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info JobInfo
+	json.NewDecoder(resp.Body).Decode(&info)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	if info.Options.Bearer != "***" {
+		t.Errorf("bearer returned in job info: %q", info.Options.Bearer)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for info.FinishedAt == nil && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		resp, err := http.Get(srv.URL + "/api/jobs/" + info.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		json.NewDecoder(resp.Body).Decode(&info)
+		resp.Body.Close()
+	}
+	if info.FinishedAt == nil {
+		t.Fatal("job not finished")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) == 0 {
+		t.Fatal("target received no requests")
+	}
+	page := received[0]
+	if got := page.Header.Get("X-Env"); got != "test" {
+		t.Errorf("X-Env = %q, want test", got)
+	}
+	if got := page.Header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization = %q, want Bearer secret", got)
+	}
+
+	resp, err = http.Post(srv.URL+"/api/jobs", "application/json",
+		strings.NewReader(`{"targets": ["`+target.URL+`"], "headers": {"Bad Name": "x"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid header: status = %d, want 400", resp.StatusCode)
+	}
+}
@@ -4,7 +4,6 @@ package pkg
 
 import (
 	"bufio"
-	"fmt"
 	"os"
 	"strings"
 )
@@ -19,12 +18,12 @@ import (
 //
 // 返回：
 //   - 处理后的 URL 列表
-func LoadFromFile(filename string) (urls []string) {
+//   - error: 文件读取错误
+func LoadFromFile(filename string) (urls []string, err error) {
 	// 打开文件
 	file, err := os.Open(filename)
 	if err != nil {
-		fmt.Println("[!] File read error")
-		os.Exit(1)
+		return nil, err
 	}
 	defer file.Close()
 
@@ -46,5 +45,21 @@ func LoadFromFile(filename string) (urls []string) {
 			urls = append(urls, "https://"+line)
 		}
 	}
-	return
+	return urls, scanner.Err()
+}
+
+// NormalizeTarget 规范化单个目标
+// 没有协议前缀的域名/IP 自动添加 https:// 前缀
+//
+// 参数：
+//   - target: 目标 URL、域名或 IP
+//
+// 返回：
+//   - 处理后的 URL
+func NormalizeTarget(target string) string {
+	target = strings.TrimSpace(target)
+	if !strings.HasPrefix(target, "http") {
+		return "https://" + target
+	}
+	return target
 }
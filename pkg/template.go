@@ -0,0 +1,212 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件实现原始请求模板和 Cookie 文件：
+// 1. 解析 Burp 风格的原始 HTTP 请求，作为每个请求的基础（请求方法、请求头、请求体）
+// 2. 模板中的 {{target}} 等占位符在发送时替换为当前请求的地址
+// 3. 读取 Netscape 格式的 Cookie 文件（curl -c、浏览器插件导出），按域名和路径为请求附加会话 Cookie
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RequestTemplate 原始请求模板
+//
+// 模板中可以使用以下占位符，发送时替换为当前请求（页面、主动探测路径或 favicon）的对应部分：
+//   - {{target}}: 完整 URL，如 https://example.com:8443/a?b=1
+//   - {{scheme}}: 协议，如 https
+//   - {{host}}: 主机和端口，如 example.com:8443（默认端口时不含端口）
+//   - {{hostname}}: 主机名，如 example.com
+//   - {{port}}: 端口，如 8443（默认端口时为 443 或 80）
+//   - {{path}}: 路径和查询参数，如 /a?b=1
+type RequestTemplate struct {
+	Method  string      // 请求方法
+	URI     string      // 请求行中的地址，可以是路径或完整 URL
+	Headers [][2]string // 请求头，保持模板中的顺序
+	Body    string      // 请求体
+}
+
+// templateHeadersSkipped 发送时由 HTTP 客户端重新计算的请求头
+var templateHeadersSkipped = map[string]bool{
+	"content-length":    true,
+	"transfer-encoding": true,
+}
+
+// LoadRequestTemplate 从文件加载原始请求模板
+//
+// 参数：
+//   - filename: 模板文件路径，内容为原始 HTTP 请求（如从 Burp 复制的请求）
+//
+// 返回：
+//   - *RequestTemplate: 请求模板
+//   - error: 文件读取或解析错误
+func LoadRequestTemplate(filename string) (*RequestTemplate, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	t, err := ParseRequestTemplate(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析请求模板 %s 失败: %v", filename, err)
+	}
+	return t, nil
+}
+
+// ParseRequestTemplate 解析原始请求模板
+// 兼容 CRLF 和 LF 换行，请求行的协议版本可以省略；Content-Length 等请求头在发送时重新计算，不带占位符的 Host 请求头被忽略
+//
+// 参数：
+//   - data: 原始 HTTP 请求
+//
+// 返回：
+//   - *RequestTemplate: 请求模板
+//   - error: 格式错误
+func ParseRequestTemplate(data []byte) (*RequestTemplate, error) {
+	data = bytes.TrimLeft(data, "\r\n\t ")
+	head, body := data, []byte(nil)
+	if idx := bytes.Index(data, []byte("\r\n\r\n")); idx >= 0 {
+		head, body = data[:idx], data[idx+4:]
+	} else if idx := bytes.Index(data, []byte("\n\n")); idx >= 0 {
+		head, body = data[:idx], data[idx+2:]
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(head), "\r\n", "\n"), "\n")
+	fields := strings.Fields(lines[0])
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("无效的请求行 %q", lines[0])
+	}
+
+	t := &RequestTemplate{
+		Method: strings.ToUpper(fields[0]),
+		URI:    fields[1],
+		Body:   string(body),
+	}
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		name, value, err := ParseHeader(line)
+		if err != nil {
+			return nil, err
+		}
+		if templateHeadersSkipped[strings.ToLower(name)] {
+			continue
+		}
+		// 从 Burp 复制的请求带有原目标的 Host，固定的 Host 会让所有目标、主动探测和 favicon 请求都发往同一个虚拟主机，
+		// 只保留带占位符的 Host（如 {{host}}），需要固定 Host 时使用 -H
+		if strings.EqualFold(name, "Host") && !strings.Contains(value, "{{") {
+			continue
+		}
+		t.Headers = append(t.Headers, [2]string{name, value})
+	}
+	return t, nil
+}
+
+// render 将占位符替换为 rawURL 的对应部分
+func (t *RequestTemplate) render(s string, u *url.URL) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+
+	port := urlPort(u)
+	path := u.RequestURI()
+
+	return strings.NewReplacer(
+		"{{target}}", u.String(),
+		"{{scheme}}", u.Scheme,
+		"{{host}}", u.Host,
+		"{{hostname}}", u.Hostname(),
+		"{{port}}", port,
+		"{{path}}", path,
+	).Replace(s)
+}
+
+// url 返回按模板请求行替换后的请求地址
+// 请求行为完整 URL 时直接使用，为路径时以 rawURL 的源为基础；
+// 请求行为固定路径时所有请求都发往该路径，通常应写作 {{path}}
+func (t *RequestTemplate) url(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	uri := t.render(t.URI, u)
+	if strings.Contains(uri, "://") {
+		return uri, nil
+	}
+	if !strings.HasPrefix(uri, "/") {
+		return "", fmt.Errorf("无效的请求地址 %q", uri)
+	}
+	return u.Scheme + "://" + u.Host + uri, nil
+}
+
+// LoadCookieJar 从 Netscape 格式的 Cookie 文件（curl -c、浏览器插件导出的 cookies.txt）加载 Cookie
+// 每行依次为 domain、include subdomains、path、secure、expires、name、value，以制表符分隔；
+// #HttpOnly_ 前缀的行同样加载，已过期的 Cookie 被忽略
+//
+// 参数：
+//   - filename: Cookie 文件路径
+//
+// 返回：
+//   - http.CookieJar: 按域名和路径匹配的 Cookie 集合，只读使用，不记录响应中的 Set-Cookie
+//   - error: 文件读取或格式错误
+func LoadCookieJar(filename string) (http.CookieJar, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	jar, _ := cookiejar.New(nil)
+	now := time.Now()
+	count := 0
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("%s 第 %d 行不是 Netscape Cookie 格式", filename, n)
+		}
+		value := ""
+		if len(fields) > 6 {
+			value = fields[6]
+		}
+		domain, includeSub, path, secure := fields[0], strings.EqualFold(fields[1], "TRUE"), fields[2], strings.EqualFold(fields[3], "TRUE")
+		if expires, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expires > 0 && time.Unix(expires, 0).Before(now) {
+			continue
+		}
+
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+		host := strings.TrimPrefix(domain, ".")
+		cookie := &http.Cookie{Name: fields[5], Value: value, Path: path, Secure: secure}
+		if (includeSub || strings.HasPrefix(domain, ".")) && net.ParseIP(host) == nil {
+			cookie.Domain = host
+		}
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: path}, []*http.Cookie{cookie})
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("%s 中没有有效的 Cookie", filename)
+	}
+	return jar, nil
+}
@@ -0,0 +1,229 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRequestTemplate(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want RequestTemplate
+	}{
+		{
+			name: "crlf with body",
+			data: "POST /login HTTP/1.1\r\nHost: old.example\r\nContent-Type: application/json\r\nContent-Length: 99\r\n\r\n{\"host\":\"{{host}}\"}",
+			want: RequestTemplate{
+				Method:  "POST",
+				URI:     "/login",
+				Headers: [][2]string{{"Content-Type", "application/json"}},
+				Body:    `{"host":"{{host}}"}`,
+			},
+		},
+		{
+			name: "lf without version",
+			data: "\n\nget {{path}}\nX-Token: a:b\nTransfer-Encoding: chunked\n",
+			want: RequestTemplate{Method: "GET", URI: "{{path}}", Headers: [][2]string{{"X-Token", "a:b"}}},
+		},
+		{
+			name: "host placeholder kept",
+			data: "GET / HTTP/1.1\nHost: {{hostname}}:8080\n\n",
+			want: RequestTemplate{Method: "GET", URI: "/", Headers: [][2]string{{"Host", "{{hostname}}:8080"}}},
+		},
+		{
+			name: "absolute uri",
+			data: "GET https://{{host}}/api HTTP/1.1",
+			want: RequestTemplate{Method: "GET", URI: "https://{{host}}/api"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRequestTemplate([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("ParseRequestTemplate: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+
+	for _, data := range []string{"", "GET\r\n\r\n", "GET / HTTP/1.1 extra\r\n\r\n", "GET / HTTP/1.1\r\nbroken header\r\n\r\n"} {
+		if _, err := ParseRequestTemplate([]byte(data)); err == nil {
+			t.Errorf("ParseRequestTemplate(%q): want error", data)
+		}
+	}
+}
+
+func TestRequestTemplateURL(t *testing.T) {
+	tests := []struct {
+		uri    string
+		rawURL string
+		want   string
+	}{
+		{"{{path}}", "https://example.com:8443/a?b=1", "https://example.com:8443/a?b=1"},
+		{"/fixed", "http://example.com/a", "http://example.com/fixed"},
+		{"/x?p={{port}}&s={{scheme}}", "https://example.com/", "https://example.com/x?p=443&s=https"},
+		{"/x?p={{port}}&h={{hostname}}", "http://example.com/", "http://example.com/x?p=80&h=example.com"},
+		{"http://proxy.local/?u={{target}}", "http://example.com/a", "http://proxy.local/?u=http://example.com/a"},
+	}
+	for _, tt := range tests {
+		got, err := (&RequestTemplate{URI: tt.uri}).url(tt.rawURL)
+		if err != nil {
+			t.Errorf("url(%q, %q): %v", tt.uri, tt.rawURL, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("url(%q, %q) = %q, want %q", tt.uri, tt.rawURL, got, tt.want)
+		}
+	}
+
+	if _, err := (&RequestTemplate{URI: "relative"}).url("http://example.com/"); err == nil {
+		t.Error("url(relative): want error")
+	}
+}
+
+func TestRequestConfigTemplate(t *testing.T) {
+	tpl, err := ParseRequestTemplate([]byte("PUT {{path}} HTTP/1.1\r\nHost: {{hostname}}\r\nX-Origin: {{scheme}}://{{host}}\r\n\r\ntarget={{target}}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &RequestConfig{Template: tpl, Headers: map[string]string{"X-Origin": "override"}}
+
+	req, err := config.newRequest(context.Background(), "https://example.com:8443/a")
+	if err != nil {
+		t.Fatalf("newRequest: %v", err)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if req.Method != "PUT" || req.URL.String() != "https://example.com:8443/a" || req.Host != "example.com" {
+		t.Errorf("method=%q url=%q host=%q", req.Method, req.URL, req.Host)
+	}
+	if string(body) != "target=https://example.com:8443/a" {
+		t.Errorf("body = %q", body)
+	}
+	// 自定义请求头覆盖模板请求头
+	if got := req.Header.Get("X-Origin"); got != "override" {
+		t.Errorf("X-Origin = %q, want override", got)
+	}
+
+	// 资源请求只使用模板请求头，不使用请求方法和请求体
+	req, err = config.newResourceRequest(context.Background(), "https://example.com:8443/favicon.ico", "https://example.com:8443/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "GET" || req.Body != nil || req.Host != "example.com" {
+		t.Errorf("resource method=%q body=%v host=%q", req.Method, req.Body, req.Host)
+	}
+}
+
+// TestResourceRequestOrigin 跨域的资源请求不发送认证信息、自定义请求头、固定的 Host 和 Cookies 配置
+func TestResourceRequestOrigin(t *testing.T) {
+	jar, err := LoadCookieJar(writeTemp(t, "cookies.txt", "cdn.example.net\tFALSE\t/\tFALSE\t0\tcdn\t1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &RequestConfig{
+		Headers:     map[string]string{"Host": "app.internal", "X-Token": "secret"},
+		Cookies:     "session=abc",
+		BearerToken: "tok",
+		UserAgents:  []string{"custom-ua"},
+		Jar:         jar,
+	}
+	const page = "https://example.com/index.html"
+
+	tests := []struct {
+		rawURL string
+		same   bool
+	}{
+		{"https://example.com:443/favicon.ico", true},
+		{"https://EXAMPLE.com/manifest.json", true},
+		{"http://example.com/favicon.ico", false},
+		{"https://example.com:8443/favicon.ico", false},
+		{"https://cdn.example.net/favicon.ico", false},
+	}
+	for _, tt := range tests {
+		req, err := config.newResourceRequest(context.Background(), tt.rawURL, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := req.Header.Get("User-Agent"); got != "custom-ua" {
+			t.Errorf("%s: User-Agent = %q", tt.rawURL, got)
+		}
+		sent := req.Host == "app.internal" || req.Header.Get("X-Token") != "" ||
+			req.Header.Get("Authorization") != "" || strings.Contains(req.Header.Get("Cookie"), "session=abc")
+		if sent != tt.same {
+			t.Errorf("%s: host=%q headers=%v, want credentials sent = %v", tt.rawURL, req.Host, req.Header, tt.same)
+		}
+	}
+
+	// Cookie 文件中的 Cookie 按域名匹配，跨域时仍然发送
+	req, _ := config.newResourceRequest(context.Background(), "https://cdn.example.net/favicon.ico", page)
+	if got := req.Header.Get("Cookie"); got != "cdn=1" {
+		t.Errorf("cross-origin Cookie = %q, want cdn=1", got)
+	}
+	// 未配置时跨域的资源请求也不携带 Shiro 检测 Cookie
+	var none *RequestConfig
+	req, _ = none.newResourceRequest(context.Background(), "https://cdn.example.net/favicon.ico", page)
+	if got := req.Header.Get("Cookie"); got != "" {
+		t.Errorf("nil config cross-origin Cookie = %q", got)
+	}
+}
+
+func TestLoadCookieJar(t *testing.T) {
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Hour).Unix()
+	path := writeTemp(t, "cookies.txt", strings.Join([]string{
+		"# Netscape HTTP Cookie File",
+		"",
+		fmt.Sprintf("example.com\tFALSE\t/\tFALSE\t%d\tsession\tabc", future),
+		".example.com\tTRUE\t/\tFALSE\t0\twide\t1",
+		fmt.Sprintf("#HttpOnly_example.com\tFALSE\t/admin\tFALSE\t%d\tadmin\tyes", future),
+		fmt.Sprintf("example.com\tFALSE\t/\tTRUE\t%d\tsecure\ts", future),
+		fmt.Sprintf("example.com\tFALSE\t/\tFALSE\t%d\texpired\tx", past),
+		"127.0.0.1\tFALSE\t/\tFALSE\t0\tip\t1",
+		"example.com\tFALSE\t/\tFALSE\t0\tempty",
+	}, "\n"))
+	jar, err := LoadCookieJar(path)
+	if err != nil {
+		t.Fatalf("LoadCookieJar: %v", err)
+	}
+
+	tests := []struct {
+		rawURL string
+		want   []string
+	}{
+		{"http://example.com/", []string{"empty", "session", "wide"}},
+		{"https://example.com/admin/users", []string{"admin", "empty", "secure", "session", "wide"}},
+		{"http://www.example.com/", []string{"wide"}},
+		{"http://127.0.0.1/", []string{"ip"}},
+		{"http://other.com/", nil},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.rawURL)
+		var got []string
+		for _, c := range jar.Cookies(u) {
+			got = append(got, c.Name)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Cookies(%s) = %v, want %v", tt.rawURL, got, tt.want)
+		}
+	}
+
+	for _, content := range []string{"example.com\tFALSE\t/\n", "# only comments\n\n"} {
+		if _, err := LoadCookieJar(writeTemp(t, "bad.txt", content)); err == nil {
+			t.Errorf("LoadCookieJar(%q): want error", content)
+		}
+	}
+	if _, err := LoadCookieJar(path + ".missing"); err == nil {
+		t.Error("LoadCookieJar(missing): want error")
+	}
+}
@@ -0,0 +1,130 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件负责 TLS 连接信息和证书的收集：
+// 1. 记录协商的 TLS 版本、加密套件和服务器证书的主体、颁发者、SAN、序列号、有效期和指纹
+// 2. 生成供 ARL 规则 cert="..." 条件匹配的证书文本
+// 3. 从证书 SAN 中提取新的扫描目标
+package pkg
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// taskCert 从证书 SAN 中发现的目标的任务类型，按主页面处理，但不再继续扩展 SAN
+const taskCert = "3"
+
+// TLSInfo TLS 连接信息和服务器证书
+type TLSInfo struct {
+	Version     string    `json:"version"`            // TLS 版本，如 TLS 1.3
+	Cipher      string    `json:"cipher"`             // 加密套件
+	Subject     string    `json:"subject"`            // 证书主体，如 CN=FortiGate,O=Fortinet,C=US
+	Issuer      string    `json:"issuer"`             // 证书颁发者
+	SANs        []string  `json:"sans,omitempty"`     // 证书中的域名和 IP（Subject Alternative Name）
+	Serial      string    `json:"serial"`             // 证书序列号（十六进制）
+	NotBefore   time.Time `json:"not_before"`         // 有效期开始时间
+	NotAfter    time.Time `json:"not_after"`          // 有效期结束时间
+	Fingerprint string    `json:"fingerprint_sha256"` // 证书 SHA-256 指纹（十六进制）
+}
+
+// tlsVersions TLS 版本名称
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// newTLSInfo 从 TLS 连接状态中提取连接信息和服务器证书
+// 非 TLS 连接返回 nil
+func newTLSInfo(state *tls.ConnectionState) *TLSInfo {
+	if state == nil {
+		return nil
+	}
+
+	info := &TLSInfo{
+		Version: tlsVersions[state.Version],
+		Cipher:  tls.CipherSuiteName(state.CipherSuite),
+	}
+	if info.Version == "" {
+		info.Version = fmt.Sprintf("0x%04x", state.Version)
+	}
+	if len(state.PeerCertificates) == 0 {
+		return info
+	}
+
+	cert := state.PeerCertificates[0]
+	info.Subject = cert.Subject.String()
+	info.Issuer = cert.Issuer.String()
+	info.SANs = certSANs(cert)
+	info.Serial = strings.ToUpper(cert.SerialNumber.Text(16))
+	info.NotBefore = cert.NotBefore
+	info.NotAfter = cert.NotAfter
+	sum := sha256.Sum256(cert.Raw)
+	info.Fingerprint = hex.EncodeToString(sum[:])
+	return info
+}
+
+// certSANs 返回证书中的 DNS 名称、IP 地址、邮箱和 URI
+func certSANs(cert *x509.Certificate) []string {
+	sans := append([]string(nil), cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+	return sans
+}
+
+// certText 返回供 cert="..." 条件匹配的证书文本，包含主体、颁发者、SAN 和序列号
+// 非 TLS 响应返回空字符串，cert 条件不会命中
+func (info *TLSInfo) certText() string {
+	if info == nil || info.Subject == "" && info.Issuer == "" {
+		return ""
+	}
+	return "subject: " + info.Subject + "\n" +
+		"issuer: " + info.Issuer + "\n" +
+		"sans: " + strings.Join(info.SANs, ", ") + "\n" +
+		"serial: " + info.Serial + "\n"
+}
+
+// sanTargets 从证书 SAN 中提取新的扫描目标
+// 只使用具体的域名，忽略通配符域名和 IP；端口与原目标一致
+//
+// 参数：
+//   - rawURL: 原目标 URL
+//   - info: 原目标的 TLS 信息
+//
+// 返回：
+//   - []string: 新目标 URL 列表，如 https://a.example.com:8443
+func sanTargets(rawURL string, info *TLSInfo) []string {
+	if info == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	var targets []string
+	for _, san := range info.SANs {
+		name := strings.ToLower(strings.TrimSuffix(san, "."))
+		if name == "" || strings.ContainsAny(name, "*@:/") || net.ParseIP(name) != nil || strings.EqualFold(name, u.Hostname()) {
+			continue
+		}
+		host := name
+		if port := u.Port(); port != "" {
+			host = net.JoinHostPort(name, port)
+		}
+		targets = append(targets, u.Scheme+"://"+host)
+	}
+	return targets
+}
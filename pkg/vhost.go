@@ -0,0 +1,249 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件实现虚拟主机识别：
+// 1. 对 IP 目标，分别以每个候选域名作为 Host 请求头和 TLS SNI 发送请求
+// 2. 与基准响应（IP 本身和不存在的域名）比较，内容不同的候选域名视为独立的虚拟主机
+// 3. 每个独立的虚拟主机单独进行指纹识别，作为单独的结果输出
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// taskVHost 虚拟主机任务类型，task[2] 为候选域名
+const taskVHost = "2"
+
+// vhostIdleTimeout 虚拟主机请求的空闲连接保留时间
+// 同一任务的页面和 favicon 请求复用连接即可，不同 IP 的连接无法复用，不需要长时间保留
+const vhostIdleTimeout = 10 * time.Second
+
+// LoadVHosts 从文件加载候选域名，每行一个，忽略空行和 # 开头的注释
+// 兼容 URL 形式（如 https://a.example.com/），只保留主机名
+//
+// 参数：
+//   - filename: 候选域名列表文件
+//
+// 返回：
+//   - []string: 候选域名列表（已去重）
+//   - error: 文件读取错误
+func LoadVHosts(filename string) ([]string, error) {
+	hosts, err := readListFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NormalizeVHosts(hosts), nil
+}
+
+// NormalizeVHosts 规范化候选域名：去掉协议、端口和路径，转为小写并去重
+func NormalizeVHosts(hosts []string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if strings.Contains(host, "://") {
+			if u, err := url.Parse(host); err == nil {
+				host = u.Host
+			}
+		}
+		host = strings.SplitN(host, "/", 2)[0]
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host != "" && !seen[host] {
+			seen[host] = true
+			result = append(result, host)
+		}
+	}
+	return result
+}
+
+// vhostBaselines 各 IP 源的基准响应，在一次扫描的所有 worker 间共享
+type vhostBaselines struct {
+	mu        sync.Mutex
+	baselines map[string][]*Response // 源 -> 基准响应
+}
+
+// add 记录源的基准响应，已存在时返回 false
+func (b *vhostBaselines) add(origin string, baseline []*Response) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.baselines[origin]; ok {
+		return false
+	}
+	b.baselines[origin] = baseline
+	return true
+}
+
+// get 返回源的基准响应
+func (b *vhostBaselines) get(origin string) []*Response {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.baselines[origin]
+}
+
+// vhostTransports 各候选域名的传输层，在一次扫描的所有 worker 间共享
+// TLS SNI 固定在传输层中，而连接池只按地址区分连接，因此每个候选域名使用独立的传输层，
+// 同一候选域名的页面请求和 favicon 请求复用连接
+type vhostTransports struct {
+	mu         sync.Mutex
+	transports map[string]http.RoundTripper // 候选域名 -> 传输层
+}
+
+// get 返回候选域名的传输层，不存在时使用 create 创建
+func (t *vhostTransports) get(host string, create func() http.RoundTripper) http.RoundTripper {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rt, ok := t.transports[host]
+	if !ok {
+		rt = create()
+		t.transports[host] = rt
+	}
+	return rt
+}
+
+// closeIdle 关闭所有传输层的空闲连接，扫描结束时调用
+func (t *vhostTransports) closeIdle() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	client := &http.Client{}
+	for _, rt := range t.transports {
+		client.Transport = rt
+		client.CloseIdleConnections()
+	}
+}
+
+// isIPTarget 判断 URL 的主机是否为 IP 地址
+func isIPTarget(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && net.ParseIP(u.Hostname()) != nil
+}
+
+// queueVHosts 为 IP 目标记录基准响应，并将每个候选域名加入任务队列
+// 基准响应为 IP 本身的响应和以不存在的域名请求得到的响应（默认虚拟主机）
+//
+// 参数：
+//   - ctx: 上下文
+//   - resp: IP 目标首页的响应
+//   - state: 扫描中共享的状态，包含任务队列和各源的基准响应
+func (s *Scanner) queueVHosts(ctx context.Context, resp *Response, state *scanState) {
+	origin := responseOrigin(resp.URL)
+	baseline := []*Response{resp}
+	bogus := fmt.Sprintf("xf-%08x.invalid", rand.Uint32())
+	if br, err := s.fetchVHost(ctx, state.vhostTransports, resp.URL, bogus); err == nil {
+		baseline = append(baseline, br)
+	}
+	if !state.vhosts.add(origin, baseline) {
+		return
+	}
+
+	s.opts.Progress.addTotal(len(s.opts.VHosts))
+	for _, host := range s.opts.VHosts {
+		state.push([]string{resp.URL, taskVHost, host})
+	}
+}
+
+// scanVHost 以候选域名请求 IP 目标，与基准响应不同时进行指纹识别
+//
+// 参数：
+//   - ctx: 上下文
+//   - task: 虚拟主机任务，task[0] 为 IP 目标 URL，task[2] 为候选域名
+//   - state: 扫描中共享的状态，包含各源的基准响应和各候选域名的传输层
+//
+// 返回：
+//   - Result: 识别结果，结果中的 VHost 为候选域名
+//   - bool: 是否为独立的虚拟主机，与基准响应相同时为 false
+//   - error: 请求错误
+func (s *Scanner) scanVHost(ctx context.Context, task []string, state *scanState) (Result, bool, error) {
+	rawURL, host := task[0], task[2]
+	resp, err := s.fetchVHost(ctx, state.vhostTransports, rawURL, host)
+	if err != nil {
+		return Result{}, false, err
+	}
+	for _, base := range state.vhosts.get(responseOrigin(rawURL)) {
+		if similarResponse(resp, base) {
+			s.opts.Logger.Debugf("%s 虚拟主机 %s 与基准响应相同", rawURL, host)
+			return Result{}, false, nil
+		}
+	}
+
+	resp.IPs = s.resolveIPs(ctx, rawURL)
+	if s.opts.CDN {
+		resp.CDN = s.detectCDN(ctx, resp)
+	}
+	client := s.vhostClient(state.vhostTransports, host, faviconTimeout)
+	get := logGetter(s.opts.Logger, httpGetter(client, s.opts.Request.withHost(host)))
+
+	result := newResult(resp, s.match(ctx, resp, true, get))
+	result.VHost = host
+	return result, true, nil
+}
+
+// fetchVHost 以指定的 Host 请求头和 TLS SNI 请求 rawURL
+func (s *Scanner) fetchVHost(ctx context.Context, transports *vhostTransports, rawURL, host string) (*Response, error) {
+	client := s.vhostClient(transports, host, s.opts.Timeout)
+
+	start := time.Now()
+	resp, err := fetch(ctx, client, s.opts.Request.withHost(host), []string{rawURL, taskVHost})
+	if err != nil {
+		s.opts.Logger.Debugf("%s %s (Host: %s) 失败: %v (%s)", s.opts.Request.method(), rawURL, host, err, time.Since(start).Round(time.Millisecond))
+		return nil, err
+	}
+	s.opts.Logger.Debugf("%s %s (Host: %s) -> %d, %d 字节 (%s)", s.opts.Request.method(), rawURL, host, resp.StatusCode, resp.Length, time.Since(start).Round(time.Millisecond))
+	return resp, nil
+}
+
+// vhostClient 返回 TLS SNI 为 host 的 HTTP 客户端，同一候选域名的客户端共用 transports 中的传输层
+func (s *Scanner) vhostClient(transports *vhostTransports, host string, timeout time.Duration) *http.Client {
+	rt := transports.get(host, func() http.RoundTripper {
+		return newHTTPClient(s.proxies, s.opts.Resolver, 0, func(transport *http.Transport) {
+			transport.TLSClientConfig.ServerName = host
+			transport.IdleConnTimeout = vhostIdleTimeout
+		}).Transport
+	})
+	return &http.Client{Timeout: timeout, Transport: rt}
+}
+
+// withHost 返回 Host 请求头为 host 的请求配置副本
+// 默认保持连接（自定义的 Connection 请求头优先），使同一候选域名的页面和 favicon 请求复用连接
+func (c *RequestConfig) withHost(host string) *RequestConfig {
+	clone := RequestConfig{}
+	if c != nil {
+		clone = *c
+	}
+	clone.Headers = map[string]string{"Host": host}
+	keepAlive := true
+	if c != nil {
+		for name, value := range c.Headers {
+			if !strings.EqualFold(name, "Host") {
+				clone.Headers[name] = value
+			}
+			if strings.EqualFold(name, "Connection") {
+				keepAlive = false
+			}
+		}
+	}
+	if keepAlive {
+		clone.Headers["Connection"] = "keep-alive"
+	}
+	return &clone
+}
+
+// similarResponse 判断两个响应是否来自同一个站点
+// 状态码和标题相同、响应体长度相差不超过 32 字节或 5% 时视为相同，容忍页面中的时间戳、随机 Token 等动态内容
+func similarResponse(a, b *Response) bool {
+	if a.StatusCode != b.StatusCode || a.Title != b.Title {
+		return false
+	}
+	diff, max := a.Length-b.Length, a.Length
+	if diff < 0 {
+		diff, max = -diff, b.Length
+	}
+	return diff <= 32 || diff*20 <= max
+}
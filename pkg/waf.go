@@ -0,0 +1,192 @@
+// Package pkg 提供 xingfinger 的核心功能
+// 本文件实现 WAF 检测：
+// 1. 响应头和 Cookie 中的 WAF 特征（如 X-Sucuri-ID、incap_ses_ Cookie）
+// 2. 拦截页面的特征（如 "The requested URL was rejected"），只在错误响应中匹配，避免正文中偶然出现的关键字造成误报
+// 3. 恶意请求对比：在主页面 URL 上附加 SQL 注入、XSS 和路径穿越参数再请求一次，响应变为拦截状态码、被重置连接或出现拦截页面特征时视为存在 WAF
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// WAFInfo WAF 检测结果
+type WAFInfo struct {
+	Name     string   `json:"name,omitempty"` // WAF 厂商，只从恶意请求对比判断时为空
+	Evidence []string `json:"evidence"`       // 判断依据，如 header:X-Sucuri-Id、body:safedog.cn、probe:403
+}
+
+// wafSignatures WAF 特征
+// header 为空时匹配响应体（只在错误响应中匹配），否则匹配该响应头；value 为小写子串，为空时只要求响应头存在
+var wafSignatures = []struct {
+	name   string
+	header string
+	value  string
+}{
+	{"Cloudflare", "", "attention required! | cloudflare"},
+	{"Cloudflare", "", "cloudflare ray id"},
+	{"Imperva Incapsula", "X-Iinfo", ""},
+	{"Imperva Incapsula", "Set-Cookie", "incap_ses_"},
+	{"Imperva Incapsula", "Set-Cookie", "visid_incap_"},
+	{"Imperva Incapsula", "", "incapsula incident id"},
+	{"Akamai", "", "errors.edgesuite.net"},
+	{"AWS WAF", "X-Amzn-Waf-Action", ""},
+	{"AWS WAF", "", "request blocked. we can't connect to the server"},
+	{"ModSecurity", "Server", "mod_security"},
+	{"ModSecurity", "", "mod_security"},
+	{"ModSecurity", "", "modsecurity"},
+	{"F5 BIG-IP ASM", "", "the requested url was rejected. please consult with your administrator"},
+	{"Sucuri", "X-Sucuri-Id", ""},
+	{"Sucuri", "", "sucuri website firewall"},
+	{"Citrix NetScaler", "Set-Cookie", "ns_af="},
+	{"Citrix NetScaler", "Via", "ns-cache"},
+	{"Barracuda", "Set-Cookie", "barra_counter_session"},
+	{"FortiWeb", "Set-Cookie", "fortiwafsid"},
+	{"Reblaze", "Set-Cookie", "rbzid"},
+	{"Wallarm", "Server", "nginx-wallarm"},
+	{"Wordfence", "", "generated by wordfence"},
+	{"安全狗", "Set-Cookie", "safedog"},
+	{"安全狗", "Server", "safedog"},
+	{"安全狗", "", "safedog.cn"},
+	{"360 网站卫士", "X-Powered-By-360wzb", ""},
+	{"360 网站卫士", "", "wangzhan.360.cn"},
+	{"阿里云 WAF", "", "errors.aliyun.com"},
+	{"腾讯云 WAF", "", "waf.tencent-cloud.com"},
+	{"创宇盾", "Set-Cookie", "__jsluid"},
+	{"创宇盾", "", "jiasule"},
+	{"百度云加速", "Server", "yunjiasu"},
+	{"长亭雷池", "", "safeline"},
+	{"绿盟 WAF", "", "nsfocus"},
+}
+
+// wafBlockStatus WAF 拦截请求时常用的状态码
+var wafBlockStatus = map[int]bool{
+	http.StatusForbidden:       true,
+	http.StatusNotAcceptable:   true,
+	http.StatusTeapot:          true,
+	http.StatusTooManyRequests: true,
+	http.StatusNotImplemented:  true,
+	999:                        true,
+}
+
+// wafProbeQuery 恶意请求附加的参数：SQL 注入、XSS、路径穿越和命令注入
+var wafProbeQuery = url.Values{
+	"xf_id":   {"1' AND 1=1 UNION SELECT 1,2,3--"},
+	"xf_q":    {"<script>alert(document.cookie)</script>"},
+	"xf_file": {"../../../../etc/passwd"},
+	"xf_cmd":  {";cat /etc/passwd"},
+}.Encode()
+
+// matchWAF 匹配响应中的 WAF 特征
+// 响应体特征只在错误响应（状态码 >= 400）中匹配
+//
+// 参数：
+//   - resp: 响应
+//   - info: 匹配到的特征追加到 info
+func matchWAF(resp *Response, info *WAFInfo) {
+	header := http.Header(resp.HeaderMap)
+	var body string
+	if resp.StatusCode >= 400 {
+		body = strings.ToLower(resp.Body)
+	}
+
+	for _, sig := range wafSignatures {
+		if sig.header == "" {
+			if body != "" && strings.Contains(body, sig.value) {
+				info.add(sig.name, "body:"+sig.value)
+			}
+			continue
+		}
+		values := header.Values(sig.header)
+		if len(values) == 0 {
+			continue
+		}
+		if sig.value == "" || strings.Contains(strings.ToLower(strings.Join(values, "; ")), sig.value) {
+			info.add(sig.name, "header:"+sig.header)
+		}
+	}
+}
+
+// detectWAF 检测目标是否有 WAF
+// 先匹配主页面响应中的特征，再发送一次恶意请求并与主页面响应对比
+//
+// 参数：
+//   - ctx: 上下文
+//   - resp: 目标主页面的响应
+//
+// 返回：
+//   - *WAFInfo: WAF 信息，没有检测到 WAF 时为 nil
+func (s *Scanner) detectWAF(ctx context.Context, resp *Response) *WAFInfo {
+	info := &WAFInfo{}
+	matchWAF(resp, info)
+	s.probeWAF(ctx, resp, info)
+
+	if len(info.Evidence) == 0 {
+		return nil
+	}
+	s.opts.Logger.Debugf("%s WAF: %s %v", resp.URL, info.Name, info.Evidence)
+	return info
+}
+
+// probeWAF 在 URL 上附加恶意参数请求一次，与主页面响应对比
+// 主页面正常（状态码 < 400）而恶意请求返回拦截状态码或连接被重置时记录 probe 依据，恶意请求响应中的特征同样记录
+func (s *Scanner) probeWAF(ctx context.Context, resp *Response, info *WAFInfo) {
+	probeURL := wafProbeURL(resp.URL)
+	if probeURL == "" {
+		return
+	}
+	if err := s.probeLimiter.wait(ctx); err != nil {
+		return
+	}
+
+	start := time.Now()
+	probe, err := fetch(ctx, s.client, s.opts.Request, []string{probeURL, "1"})
+	if err != nil {
+		s.opts.Logger.Debugf("%s WAF 探测失败: %v (%s)", resp.URL, err, time.Since(start).Round(time.Millisecond))
+		if ctx.Err() == nil && resp.StatusCode < 400 && isConnReset(err) {
+			info.add("", "probe:reset")
+		}
+		return
+	}
+	s.opts.Logger.Debugf("%s WAF 探测 -> %d (%s)", resp.URL, probe.StatusCode, time.Since(start).Round(time.Millisecond))
+
+	matchWAF(probe, info)
+	if resp.StatusCode < 400 && wafBlockStatus[probe.StatusCode] {
+		info.add("", fmt.Sprintf("probe:%d", probe.StatusCode))
+	}
+}
+
+// wafProbeURL 在 URL 的查询参数后附加恶意参数，URL 无效时返回空字符串
+func wafProbeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	if u.RawQuery != "" {
+		u.RawQuery += "&"
+	}
+	u.RawQuery += wafProbeQuery
+	u.Fragment = ""
+	return u.String()
+}
+
+// isConnReset 判断错误是否为连接被重置或响应中途被关闭（部分 WAF 直接断开恶意请求的连接）
+// 超时等其他错误不视为拦截
+func isConnReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// add 记录一条判断依据，厂商以第一个确定厂商的依据为准
+func (info *WAFInfo) add(name, evidence string) {
+	if info.Name == "" {
+		info.Name = name
+	}
+	info.Evidence = appendUnique(info.Evidence, evidence)
+}